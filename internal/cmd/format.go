@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/s-hammon/agfapi/pkg/agfa"
+	"gopkg.in/yaml.v3"
+)
+
+var formats = []string{"json", "ndjson", "csv", "tsv", "table", "yaml"}
+
+// defaultColumns are shown by the tabular formats unless --columns is set.
+var defaultColumns = []string{"accession", "patient", "procedure", "priority", "status", "occurrence"}
+
+// columnAliases name common paths. A path selects a value from the Task
+// ("task.") or the ServiceRequest ("serviceRequest.", the default) of a
+// worklist item by JSON member name or array index; name[path=value]
+// picks the first array element matching, and a|b falls back to b when a
+// is empty.
+var columnAliases = map[string]string{
+	"accession":  "serviceRequest.identifier[type.coding.0.code=ACSN].value|serviceRequest.identifier.0.value",
+	"patient":    "serviceRequest.subject.display|task.for.display",
+	"procedure":  "serviceRequest.code.coding.0.display|serviceRequest.code.text",
+	"priority":   "serviceRequest.priority|task.priority",
+	"status":     "task.status",
+	"occurrence": "serviceRequest.occurrenceDateTime",
+}
+
+// column is a --columns entry: its header and the path it selects.
+type column struct {
+	name string
+	path string
+}
+
+func parseColumns(names []string) []column {
+	if len(names) == 0 {
+		names = defaultColumns
+	}
+
+	cols := make([]column, len(names))
+	for i, name := range names {
+		path, ok := columnAliases[name]
+		if !ok {
+			path = name
+		}
+		cols[i] = column{name: name, path: path}
+	}
+
+	return cols
+}
+
+// itemDoc turns a worklist item into the generic JSON document columns
+// select from, scrubbed of PHI if --redact-phi is set.
+func itemDoc(item agfa.WorklistItem) (map[string]any, error) {
+	b, err := json.Marshal(map[string]any{"task": item.Task, "serviceRequest": item.ServiceRequest})
+	if err != nil {
+		return nil, err
+	}
+	if redactPhi {
+		b = redactor.JSON(b)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var doc map[string]any
+	return doc, dec.Decode(&doc)
+}
+
+// selectPath returns the value at path in doc, or "" if there is none.
+func selectPath(doc map[string]any, path string) string {
+	for alt := range strings.SplitSeq(path, "|") {
+		if !strings.HasPrefix(alt, "task.") && !strings.HasPrefix(alt, "serviceRequest.") {
+			alt = "serviceRequest." + alt
+		}
+
+		if v := formatValue(lookup(doc, splitPath(alt))); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// splitPath splits a path on the dots outside of [filters].
+func splitPath(path string) []string {
+	var segs []string
+	depth, start := 0, 0
+	for i, c := range path {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '.':
+			if depth == 0 {
+				segs = append(segs, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(segs, path[start:])
+}
+
+func lookup(v any, segs []string) any {
+	for _, seg := range segs {
+		name, filter, hasFilter := strings.Cut(seg, "[")
+
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[name]
+		case []any:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+
+		if hasFilter {
+			v = filterArray(v, strings.TrimSuffix(filter, "]"))
+		}
+	}
+
+	return v
+}
+
+// filterArray returns the first element of v where path=value.
+func filterArray(v any, filter string) any {
+	path, want, _ := strings.Cut(filter, "=")
+
+	elems, _ := v.([]any)
+	for _, elem := range elems {
+		if formatValue(lookup(elem, splitPath(path))) == want {
+			return elem
+		}
+	}
+
+	return nil
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// orderedRow is a row of --columns output, marshalled in column order.
+type orderedRow struct {
+	cols   []column
+	values []string
+}
+
+func (r orderedRow) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, col := range r.cols {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(col.name)
+		v, _ := json.Marshal(r.values[i])
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// renderWorklist writes the worklist in the given format. The record
+// formats (json, ndjson, yaml) print whole ServiceRequests unless columns
+// are given; the tabular ones print the default columns.
+func renderWorklist(w io.Writer, format string, columns []string, items []agfa.WorklistItem) error {
+	cols := parseColumns(columns)
+
+	rows := make([]orderedRow, len(items))
+	for i, item := range items {
+		doc, err := itemDoc(item)
+		if err != nil {
+			return err
+		}
+
+		rows[i] = orderedRow{cols: cols, values: make([]string, len(cols))}
+		for j, col := range cols {
+			rows[i].values[j] = selectPath(doc, col.path)
+		}
+	}
+
+	// never nil, so an empty worklist prints as [] rather than null
+	records := make([]any, 0, len(items))
+	if len(columns) != 0 {
+		for _, row := range rows {
+			records = append(records, row)
+		}
+	} else {
+		for _, item := range items {
+			records = append(records, item.ServiceRequest)
+		}
+	}
+
+	switch format {
+	case "json":
+		prettyPrintJson(w, records)
+		return nil
+	case "ndjson":
+		for _, rec := range records {
+			b, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			if redactPhi {
+				b = redactor.JSON(b)
+			}
+			fmt.Fprintln(w, string(b))
+		}
+		return nil
+	case "yaml":
+		return writeYaml(w, records)
+	case "csv", "tsv":
+		cw := csv.NewWriter(w)
+		if format == "tsv" {
+			cw.Comma = '\t'
+		}
+		cw.Write(columnNames(cols))
+		for _, row := range rows {
+			cw.Write(row.values)
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		header := columnNames(cols)
+		for i := range header {
+			header[i] = strings.ToUpper(header[i])
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			values := make([]string, len(row.values))
+			for i, v := range row.values {
+				// keep cells on one line so the columns stay aligned
+				values[i] = strings.Join(strings.Fields(v), " ")
+			}
+			fmt.Fprintln(tw, strings.Join(values, "\t"))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("invalid format %q", format)
+	}
+}
+
+func columnNames(cols []column) []string {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.name
+	}
+
+	return names
+}
+
+// writeYaml converts records to YAML by way of their JSON, which keeps the
+// FHIR member names and order.
+func writeYaml(w io.Writer, records []any) error {
+	b, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	if redactPhi {
+		b = redactor.JSON(b)
+	}
+
+	// JSON is YAML, so this parses; only the flow style needs undoing
+	var doc yaml.Node
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	blockStyle(&doc)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err = enc.Encode(&doc); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
+
+func checkFormat(format string) error {
+	if !slices.Contains(formats, format) {
+		return fmt.Errorf("invalid --format %q: expected one of %s", format, strings.Join(formats, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/s-hammon/agfapi/pkg/agfa"
+	"github.com/stretchr/testify/require"
+)
+
+func newItem(t *testing.T, task, svcReq string) agfa.WorklistItem {
+	t.Helper()
+
+	var item agfa.WorklistItem
+	require.NoError(t, json.Unmarshal([]byte(task), &item.Task))
+	require.NoError(t, json.Unmarshal([]byte(svcReq), &item.ServiceRequest))
+
+	return item
+}
+
+func testItems(t *testing.T) []agfa.WorklistItem {
+	return []agfa.WorklistItem{
+		newItem(t,
+			`{"resourceType": "Task", "status": "ready", "owner": {"display": "Dr. Who"}}`,
+			`{
+				"resourceType": "ServiceRequest",
+				"identifier": [
+					{"system": "urn:mrn", "value": "MRN1"},
+					{"type": {"coding": [{"code": "ACSN"}]}, "value": "A100"}
+				],
+				"priority": "stat",
+				"code": {"coding": [{"code": "CT1", "display": "CT HEAD, \"W/O\""}]},
+				"subject": {"reference": "Patient/1", "display": "DOE, JANE"},
+				"occurrenceDateTime": "2025-11-26T08:00:00Z"
+			}`),
+		newItem(t,
+			`{"resourceType": "Task", "status": "in-progress", "priority": "routine", "for": {"display": "ROE, RICHARD"}}`,
+			`{
+				"resourceType": "ServiceRequest",
+				"identifier": [{"value": "A200"}],
+				"code": {"text": "MR\tKNEE"}
+			}`),
+	}
+}
+
+func setRedactPhi(t *testing.T, on bool) {
+	t.Helper()
+
+	prevOn, prev := redactPhi, redactor
+	redactPhi, redactor = on, &agfa.Redactor{PHI: on}
+	t.Cleanup(func() { redactPhi, redactor = prevOn, prev })
+}
+
+func TestCheckFormat(t *testing.T) {
+	for _, f := range formats {
+		require.NoError(t, checkFormat(f))
+	}
+	require.ErrorContains(t, checkFormat("xml"), `invalid --format "xml"`)
+}
+
+func TestParseColumns(t *testing.T) {
+	cols := parseColumns(nil)
+	require.Equal(t, defaultColumns, columnNames(cols))
+	require.Equal(t, columnAliases["status"], cols[4].path)
+
+	cols = parseColumns([]string{"accession", "task.owner.display"})
+	require.Equal(t, columnAliases["accession"], cols[0].path)
+	require.Equal(t, column{name: "task.owner.display", path: "task.owner.display"}, cols[1])
+}
+
+func TestSelectPath(t *testing.T) {
+	setRedactPhi(t, false)
+
+	items := testItems(t)
+	doc, err := itemDoc(items[0])
+	require.NoError(t, err)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"status", ""},
+		{"task.status", "ready"},
+		{"priority", "stat"},
+		{"serviceRequest.priority", "stat"},
+		{"identifier.0.value", "MRN1"},
+		{"identifier.5.value", ""},
+		{"identifier.x.value", ""},
+		{"identifier[type.coding.0.code=ACSN].value", "A100"},
+		{"identifier[system=urn:other].value", ""},
+		{"task.missing|task.owner.display", "Dr. Who"},
+		{"subject", `{"display":"DOE, JANE","reference":"Patient/1"}`},
+		{columnAliases["procedure"], `CT HEAD, "W/O"`},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, selectPath(doc, tt.path), tt.path)
+	}
+
+	// aliases fall back when the first choice is missing
+	doc, err = itemDoc(items[1])
+	require.NoError(t, err)
+	require.Equal(t, "A200", selectPath(doc, columnAliases["accession"]))
+	require.Equal(t, "ROE, RICHARD", selectPath(doc, columnAliases["patient"]))
+	require.Equal(t, "MR\tKNEE", selectPath(doc, columnAliases["procedure"]))
+	require.Equal(t, "routine", selectPath(doc, columnAliases["priority"]))
+}
+
+func render(t *testing.T, format string, columns []string, items []agfa.WorklistItem) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, renderWorklist(&buf, format, columns, items))
+	return buf.String()
+}
+
+func TestRenderWorklist_CSV(t *testing.T) {
+	setRedactPhi(t, false)
+
+	require.Equal(t, `accession,patient,procedure,priority,status,occurrence
+A100,"DOE, JANE","CT HEAD, ""W/O""",stat,ready,2025-11-26T08:00:00Z
+A200,"ROE, RICHARD",MR	KNEE,routine,in-progress,
+`, render(t, "csv", nil, testItems(t)))
+
+	require.Equal(t, "accession\tpatient\tprocedure\tpriority\tstatus\toccurrence\n"+
+		"A100\tDOE, JANE\t\"CT HEAD, \"\"W/O\"\"\"\tstat\tready\t2025-11-26T08:00:00Z\n"+
+		"A200\tROE, RICHARD\t\"MR\tKNEE\"\troutine\tin-progress\t\n",
+		render(t, "tsv", nil, testItems(t)))
+}
+
+func TestRenderWorklist_Table(t *testing.T) {
+	setRedactPhi(t, true)
+
+	require.Equal(t, `ACCESSION   PATIENT     PROCEDURE       STATUS
+[REDACTED]  [REDACTED]  CT HEAD, "W/O"  ready
+[REDACTED]  [REDACTED]  MR KNEE         in-progress
+`, render(t, "table", []string{"accession", "patient", "procedure", "status"}, testItems(t)))
+}
+
+func TestRenderWorklist_Records(t *testing.T) {
+	setRedactPhi(t, false)
+
+	items := testItems(t)
+	cols := []string{"accession", "task.status"}
+
+	require.Equal(t, `{"accession":"A100","task.status":"ready"}
+{"accession":"A200","task.status":"in-progress"}
+`, render(t, "ndjson", cols, items))
+
+	require.JSONEq(t, `[
+		{"accession": "A100", "task.status": "ready"},
+		{"accession": "A200", "task.status": "in-progress"}
+	]`, render(t, "json", cols, items))
+
+	require.Equal(t, `- accession: A100
+  task.status: ready
+- accession: A200
+  task.status: in-progress
+`, render(t, "yaml", cols, items))
+
+	// without columns the records are the ServiceRequests
+	var svcReqs []agfa.ServiceRequest
+	require.NoError(t, json.Unmarshal([]byte(render(t, "json", nil, items)), &svcReqs))
+	require.Len(t, svcReqs, 2)
+	require.Equal(t, "stat", svcReqs[0].Priority)
+	require.Contains(t, render(t, "yaml", nil, items), "occurrenceDateTime: \"2025-11-26T08:00:00Z\"")
+}
+
+func TestRenderWorklist_Empty(t *testing.T) {
+	setRedactPhi(t, false)
+
+	require.Equal(t, "[]\n", render(t, "json", nil, nil))
+	require.Equal(t, "[]\n", render(t, "yaml", nil, nil))
+	require.Empty(t, render(t, "ndjson", nil, nil))
+	require.Equal(t, "accession,patient,procedure,priority,status,occurrence\n", render(t, "csv", nil, nil))
+}
@@ -1,14 +1,17 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"net/url"
 	"os"
 	"strings"
 
 	"github.com/s-hammon/agfapi/pkg/agfa"
-	"github.com/s-hammon/p"
 	"github.com/spf13/cobra"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -16,6 +19,9 @@ import (
 
 var (
 	queryParams []string
+	allPages    bool
+	maxPages    int
+	maxResults  int
 
 	caser = cases.Title(language.AmericanEnglish)
 )
@@ -28,11 +34,19 @@ var requestCmd = &cobra.Command{
 		parts := strings.Split(args[0], "/")
 		parts[0] = caser.String(parts[0])
 		endpoint := strings.Join(parts, "/")
-		params := p.StringDeserialize(queryParams)
+		params, err := parseQueryParams(queryParams)
+		if err != nil {
+			return err
+		}
 
-		var res map[string]any
-		if err = client.Get(endpoint, params, &res); err != nil {
-			return fmt.Errorf("client.Get: %v", err)
+		var res any
+		if allPages {
+			res, err = searchAll(cmd.Context(), endpoint, params)
+		} else {
+			err = client.GetQuery(cmd.Context(), endpoint, params, &res)
+		}
+		if err != nil {
+			return fhirError("client.GetQuery", err)
 		}
 
 		prettyPrintJson(out, res)
@@ -45,7 +59,10 @@ var requestCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(requestCmd)
-	requestCmd.Flags().StringSliceVarP(&queryParams, "query-param", "q", []string{}, "specify query param (key=value)")
+	requestCmd.Flags().StringArrayVarP(&queryParams, "query-param", "q", []string{}, "specify query param (key=value); repeat a key to send it more than once")
+	requestCmd.Flags().BoolVar(&allPages, "all-pages", false, "follow next links and merge every page into one searchset Bundle")
+	requestCmd.Flags().IntVar(&maxPages, "max-pages", 0, "with --all-pages, stop after this many pages (0 for no limit)")
+	requestCmd.Flags().IntVar(&maxResults, "max-results", 0, "with --all-pages, stop after this many entries (0 for no limit)")
 }
 
 func requestPreRun(cmd *cobra.Command, args []string) (err error) {
@@ -54,7 +71,7 @@ func requestPreRun(cmd *cobra.Command, args []string) (err error) {
 		return err
 	}
 
-	return newClient()
+	return newClient(cmd.Context())
 }
 
 func checkOutPath() (err error) {
@@ -71,17 +88,195 @@ func checkOutPath() (err error) {
 	return nil
 }
 
-func newClient() (err error) {
-	log.Println("logging in...")
-	client, err = agfa.NewClient(baseUrl).Session(agfa.SessionParams{
-		Username: user,
-		Password: pass,
-		ClientId: clientId,
-	})
+// newClient sets up the client, reusing the cached session if there is a
+// live one and logging in otherwise.
+func newClient(ctx context.Context) error {
+	if noCache {
+		return login(ctx)
+	}
+
+	cached, err := loadSession()
+	if err != nil {
+		log.Println(err)
+	}
+	if cached == nil || cached.Expired() {
+		return login(ctx)
+	}
+
+	if err = buildClient(); err != nil {
+		return err
+	}
+	client.Restore(cached.SessionState)
+	log.Println("using cached session")
+
+	return nil
+}
+
+// buildClient creates the client from the flags, without logging in. The
+// session cache is updated whenever the client has to log in again.
+func buildClient() error {
+	auth, err := authenticator()
 	if err != nil {
-		return fmt.Errorf("agfa.NewClient: %v", err)
+		return err
 	}
 
-	log.Println("client logged in")
+	client = agfa.NewClient(baseUrl, append(clientOpts(), agfa.WithAuthenticator(auth), agfa.WithReloginHook(func(err error) {
+		if err == nil {
+			saveSession()
+		}
+	}))...)
+	client.TokenUrl = tokenUrl
+
 	return nil
 }
+
+// authenticator builds the agfa.Authenticator selected with --auth.
+func authenticator() (agfa.Authenticator, error) {
+	switch authMode {
+	case "session":
+		return &agfa.SessionAuthenticator{Params: agfa.SessionParams{
+			Username: user,
+			Password: pass,
+			ClientId: clientId,
+			FormId:   loginFormId,
+			OTP:      otpSource(),
+		}}, nil
+	case "client-credentials":
+		return &agfa.OAuth2Authenticator{Grant: agfa.ClientCredentials{
+			ClientId:     clientId,
+			ClientSecret: clientSecret,
+			Scopes:       scopes,
+		}}, nil
+	case "password":
+		return &agfa.OAuth2Authenticator{Grant: agfa.PasswordGrant{
+			ClientId:     clientId,
+			ClientSecret: clientSecret,
+			Username:     user,
+			Password:     pass,
+			Scopes:       scopes,
+		}}, nil
+	case "smart":
+		if keyFile == "" {
+			return nil, errors.New("--auth smart requires --private-key")
+		}
+		pemBytes, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read private key: %v", err)
+		}
+		key, err := agfa.ParsePrivateKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse private key: %v", err)
+		}
+		return &agfa.OAuth2Authenticator{Grant: agfa.SMARTBackend{
+			ClientId: clientId,
+			KeyId:    keyId,
+			Key:      key,
+			Scopes:   scopes,
+		}}, nil
+	case "bearer":
+		if bearerToken == "" {
+			return nil, errors.New("--auth bearer requires --token or AGFA_TOKEN")
+		}
+		return agfa.BearerToken(bearerToken), nil
+	case "cookie-file":
+		if cookieFile == "" {
+			return nil, errors.New("--auth cookie-file requires --cookie-file")
+		}
+		return &agfa.CookieFile{Path: cookieFile}, nil
+	default:
+		return nil, fmt.Errorf("invalid --auth %q", authMode)
+	}
+}
+
+// parseQueryParams turns key=value pairs into a query, keeping repeated keys.
+func parseQueryParams(pairs []string) (url.Values, error) {
+	query := url.Values{}
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid query param %q: expected key=value", pair)
+		}
+		query.Add(k, v)
+	}
+
+	return query, nil
+}
+
+// searchAll pulls every page of a search into a single searchset Bundle.
+// Its total is left out if --max-pages or --max-results may have cut the
+// results short, since the entries then aren't the whole result set.
+func searchAll(ctx context.Context, resourceType string, params url.Values) (map[string]any, error) {
+	entries := make([]agfa.SearchEntry, 0)
+	for entry, err := range client.Search(ctx, resourceType, params, agfa.WithMaxPages(maxPages), agfa.WithMaxResults(maxResults)) {
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	bundle := map[string]any{
+		"resourceType": "Bundle",
+		"type":         "searchset",
+		"entry":        entries,
+	}
+	if maxPages <= 0 && maxResults <= 0 {
+		bundle["total"] = len(entries)
+	}
+
+	return bundle, nil
+}
+
+// clientOpts collects the agfa.Client options set by the root flags.
+func clientOpts() []func(*agfa.Client) {
+	opts := []func(*agfa.Client){
+		agfa.WithRetryPolicy(agfa.RetryPolicy{
+			MaxAttempts:     retries,
+			BaseDelay:       retryWait,
+			MaxDelay:        retryMaxWait,
+			RetryableStatus: retryStatus,
+		}),
+		agfa.WithRateLimit(rps),
+		agfa.WithConcurrency(concurrency),
+		agfa.WithBatchSize(batchSize),
+		agfa.WithMinTLSVersion(tlsVersions[tlsMinVersion]),
+		agfa.WithTimeout(timeout),
+		agfa.WithUserAgent(userAgent),
+		agfa.WithRedactor(redactor),
+	}
+
+	if proxyUrl != "" {
+		u, _ := url.Parse(proxyUrl) // checked by rootCmd
+		opts = append(opts, agfa.WithProxy(u))
+	}
+
+	if caFile != "" {
+		opts = append(opts, agfa.WithCAFile(caFile))
+	}
+	if clientCert != "" {
+		opts = append(opts, agfa.WithClientCertFile(clientCert, clientKey))
+	}
+	if verbose {
+		logger := slog.New(slog.NewTextHandler(rootCmd.ErrOrStderr(), nil))
+		opts = append(opts, agfa.WithHook(agfa.NewSlogHook(logger)))
+	}
+	if trace {
+		opts = append(opts, agfa.WithHook(&agfa.TraceHook{W: rootCmd.ErrOrStderr()}))
+	}
+	if insecure {
+		log.Println("WARNING: not verifying the server's TLS certificate")
+		opts = append(opts, agfa.WithInsecureSkipVerify())
+	}
+
+	return opts
+}
+
+// fhirError expands any OperationOutcome the server returned into one
+// readable line per issue, instead of dumping the raw response body.
+func fhirError(op string, err error) error {
+	var fe *agfa.Error
+	if errors.As(err, &fe) && fe.Outcome != nil {
+		return fmt.Errorf("%s: %d %s\n%s", op, fe.StatusCode, redactor.URL(fe.Url), redactor.String(strings.TrimRight(fe.Outcome.String(), "\n")))
+	}
+
+	return fmt.Errorf("%s: %v", op, redactor.Error(err))
+}
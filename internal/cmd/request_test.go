@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/s-hammon/agfapi/pkg/agfa"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchAll_Total(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next := ""
+		if r.URL.Query().Get("page") == "" {
+			next = `{"relation": "next", "url": "` + ts.URL + `/Task?page=2"}`
+		}
+		io.WriteString(w, `{"resourceType": "Bundle", "type": "searchset", "total": 2, "link": [`+next+`],
+			"entry": [{"resource": {"resourceType": "Task"}}]}`)
+	}))
+	defer ts.Close()
+
+	prev, prevPages := client, maxPages
+	t.Cleanup(func() { client, maxPages = prev, prevPages })
+	client = agfa.NewClient(ts.URL)
+
+	bundle, err := searchAll(context.Background(), "Task", nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, bundle["total"])
+	require.Len(t, bundle["entry"], 2)
+
+	maxPages = 1
+	bundle, err = searchAll(context.Background(), "Task", nil)
+	require.NoError(t, err)
+	require.NotContains(t, bundle, "total")
+	require.Len(t, bundle["entry"], 1)
+}
@@ -2,11 +2,15 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
+	"time"
 
 	"github.com/s-hammon/agfapi/pkg/agfa"
 	"github.com/s-hammon/p"
@@ -19,6 +23,38 @@ var (
 	baseUrl  string
 	clientId string
 
+	authMode     string
+	clientSecret string
+	tokenUrl     string
+	keyFile      string
+	keyId        string
+	scopes       []string
+	bearerToken  string
+	cookieFile   string
+	loginFormId  string
+	otpCode      string
+	totpSecret   string
+
+	caFile        string
+	clientCert    string
+	clientKey     string
+	tlsMinVersion string
+	insecure      bool
+
+	proxyUrl  string
+	timeout   time.Duration
+	userAgent string
+
+	verbose   bool
+	trace     bool
+	redactPhi bool
+	redactor  *agfa.Redactor
+
+	retries      int
+	retryWait    time.Duration
+	retryMaxWait time.Duration
+	retryStatus  []int
+
 	client *agfa.Client
 )
 
@@ -30,23 +66,84 @@ var rootCmd = &cobra.Command{
 			return fmt.Errorf("invalid base url: %v", err)
 		}
 
+		if _, ok := tlsVersions[tlsMinVersion]; !ok {
+			return fmt.Errorf("invalid --tls-min-version %q: expected 1.0, 1.1, 1.2 or 1.3", tlsMinVersion)
+		}
+		redactor = &agfa.Redactor{PHI: redactPhi}
+
+		if proxyUrl != "" {
+			if _, err = url.Parse(proxyUrl); err != nil {
+				return fmt.Errorf("invalid --proxy: %v", err)
+			}
+		}
+		if (clientCert == "") != (clientKey == "") {
+			return errors.New("--client-cert and --client-key must be given together")
+		}
+
 		return nil
 	},
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&user, "username", "u", "", "username for session-based login")
-	rootCmd.PersistentFlags().StringVarP(&pass, "password", "p", "", "password for session-based login")
-	rootCmd.PersistentFlags().StringVar(&clientId, "client-id", "", "client id for session-based login")
+	rootCmd.PersistentFlags().StringVarP(&user, "username", "u", "", "username for session-based login and the password grant")
+	rootCmd.PersistentFlags().StringVarP(&pass, "password", "p", "", "password for session-based login and the password grant")
+	rootCmd.PersistentFlags().StringVar(&clientId, "client-id", "", "client id for session-based login and OAuth2")
+	rootCmd.PersistentFlags().StringVar(&loginFormId, "login-form-id", "", "id of the login form, if the login page has several (session)")
+	rootCmd.PersistentFlags().StringVar(&otpCode, "otp", "", "one-time code, if the login asks for one (session)")
+	rootCmd.PersistentFlags().StringVar(&authMode, "auth", "session", "how to authenticate: session, client-credentials, password, smart, bearer or cookie-file")
+	rootCmd.PersistentFlags().StringVar(&clientSecret, "client-secret", "", "OAuth2 client secret (client-credentials, password)")
+	rootCmd.PersistentFlags().StringVar(&tokenUrl, "token-url", "", "OAuth2 token endpoint (discovered from the login redirect if unset)")
+	rootCmd.PersistentFlags().StringVar(&keyFile, "private-key", "", "PEM private key for SMART backend services (smart)")
+	rootCmd.PersistentFlags().StringVar(&keyId, "key-id", "", "kid of the SMART backend services key (smart)")
+	rootCmd.PersistentFlags().StringSliceVar(&scopes, "scope", nil, "OAuth2 scopes to request")
+	rootCmd.PersistentFlags().StringVar(&bearerToken, "token", "", "access token to send as is (bearer)")
+	rootCmd.PersistentFlags().StringVar(&cookieFile, "cookie-file", "", "Netscape cookies.txt export of a browser session (cookie-file)")
+	rootCmd.PersistentFlags().StringVar(&caFile, "ca-file", "", "PEM bundle of extra CAs to trust, e.g. an internal CA")
+	rootCmd.PersistentFlags().StringVar(&clientCert, "client-cert", "", "PEM client certificate for mutual TLS")
+	rootCmd.PersistentFlags().StringVar(&clientKey, "client-key", "", "PEM key of the client certificate")
+	rootCmd.PersistentFlags().StringVar(&tlsMinVersion, "tls-min-version", "1.2", "lowest TLS version to accept")
+	rootCmd.PersistentFlags().BoolVar(&insecure, "insecure-skip-verify", false, "don't verify the server's certificate (test systems only!)")
+	rootCmd.PersistentFlags().StringVar(&proxyUrl, "proxy", "", "HTTP(S) proxy url (defaults to HTTPS_PROXY/HTTP_PROXY)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "max time per HTTP request (0 for no limit)")
+	rootCmd.PersistentFlags().StringVar(&userAgent, "user-agent", "agfapi", "User-Agent header sent to the server")
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "log every HTTP exchange to stderr")
+	rootCmd.PersistentFlags().BoolVar(&trace, "trace", false, "dump the headers of every HTTP request and response to stderr (credentials redacted)")
+	rootCmd.PersistentFlags().BoolVar(&redactPhi, "redact-phi", false, "scrub patient names and identifiers from output, logs and errors")
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", agfa.DefaultRetryPolicy.MaxAttempts, "max attempts per GET request (1 disables retries)")
+	rootCmd.PersistentFlags().DurationVar(&retryWait, "retry-wait", agfa.DefaultRetryPolicy.BaseDelay, "initial backoff between retries")
+	rootCmd.PersistentFlags().DurationVar(&retryMaxWait, "retry-max-wait", agfa.DefaultRetryPolicy.MaxDelay, "max backoff between retries, including Retry-After")
+	rootCmd.PersistentFlags().IntSliceVar(&retryStatus, "retry-status", agfa.DefaultRetryPolicy.RetryableStatus, "response codes to retry")
 
 	user = p.Coalesce(user, os.Getenv("AGFA_USER"))
 	pass = p.Coalesce(pass, os.Getenv("AGFA_PASS"))
 	baseUrl = os.Getenv("AGFA_URL")
 	clientId = p.Coalesce(clientId, os.Getenv("AGFA_CLIENT"))
+	clientSecret = p.Coalesce(clientSecret, os.Getenv("AGFA_CLIENT_SECRET"))
+	tokenUrl = p.Coalesce(tokenUrl, os.Getenv("AGFA_TOKEN_URL"))
+	keyFile = p.Coalesce(keyFile, os.Getenv("AGFA_PRIVATE_KEY"))
+	bearerToken = p.Coalesce(bearerToken, os.Getenv("AGFA_TOKEN"))
+	totpSecret = os.Getenv("AGFA_TOTP_SECRET")
+	caFile = p.Coalesce(caFile, os.Getenv("AGFA_CA_FILE"))
+	clientCert = p.Coalesce(clientCert, os.Getenv("AGFA_CLIENT_CERT"))
+	clientKey = p.Coalesce(clientKey, os.Getenv("AGFA_CLIENT_KEY"))
+	tlsMinVersion = p.Coalesce(os.Getenv("AGFA_TLS_MIN_VERSION"), tlsMinVersion)
+	insecure = os.Getenv("AGFA_INSECURE_SKIP_VERIFY") == "true"
+	proxyUrl = os.Getenv("AGFA_PROXY")
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
 }
 
 func Execute(args []string, in io.Reader, out, err io.Writer) int {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	rootCmd.SetIn(in)
+
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			return exitErr.ExitCode()
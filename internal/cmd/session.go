@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/s-hammon/agfapi/pkg/agfa"
+	"github.com/spf13/cobra"
+)
+
+var (
+	noCache   bool
+	logoutAll bool
+)
+
+// cachedSession is what gets persisted between invocations, along with
+// enough of the settings it was created with to tell whether it applies.
+type cachedSession struct {
+	Auth     string `json:"auth"`
+	User     string `json:"user,omitempty"`
+	ClientId string `json:"clientId,omitempty"`
+	agfa.SessionState
+}
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Log in and cache the session for later commands",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := login(cmd.Context()); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(cmd.OutOrStdout(), "logged in to %s\n", baseUrl)
+		return nil
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Forget the cached session",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := sessionPath()
+		if err != nil {
+			return err
+		}
+		if logoutAll {
+			path = filepath.Dir(path)
+		}
+
+		if err = os.RemoveAll(path); err != nil {
+			return fmt.Errorf("couldn't remove session cache: %v", err)
+		}
+
+		fmt.Fprintln(cmd.OutOrStdout(), "logged out")
+		return nil
+	},
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the cached session and check whether the server still accepts it",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cached, err := loadSession()
+		if err != nil {
+			return err
+		}
+		if cached == nil {
+			return errors.New("not logged in (run agfapi login)")
+		}
+
+		w := cmd.OutOrStdout()
+		fmt.Fprintf(w, "server:    %s\n", cached.BaseUrl)
+		fmt.Fprintf(w, "auth:      %s\n", cached.Auth)
+		if cached.User != "" {
+			fmt.Fprintf(w, "user:      %s\n", cached.User)
+		}
+		if cached.ClientId != "" {
+			fmt.Fprintf(w, "client id: %s\n", cached.ClientId)
+		}
+		fmt.Fprintf(w, "logged in: %s\n", cached.Saved.Format(time.RFC3339))
+		if cached.Token != nil && !cached.Token.Expiry.IsZero() {
+			fmt.Fprintf(w, "expires:   %s\n", cached.Token.Expiry.Format(time.RFC3339))
+		}
+
+		status := "valid"
+		if err = probeSession(cmd.Context(), cached.SessionState); errors.Is(err, agfa.ErrSessionExpired) {
+			status = "expired"
+		} else if err != nil {
+			status = fmt.Sprintf("unknown (%v)", err)
+		}
+		fmt.Fprintf(w, "session:   %s\n", status)
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd, logoutCmd, whoamiCmd)
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-session-cache", false, "neither reuse nor save a cached session")
+	logoutCmd.Flags().BoolVar(&logoutAll, "all", false, "forget the sessions of every server and user")
+}
+
+// login authenticates from scratch, caching the new session.
+func login(ctx context.Context) error {
+	if err := buildClient(); err != nil {
+		return err
+	}
+
+	log.Println("logging in...")
+	if err := client.Login(ctx); err != nil {
+		return fmt.Errorf("agfa.NewClient: %v", err)
+	}
+	log.Println("client logged in")
+
+	saveSession()
+	return nil
+}
+
+// otpSource picks where one-time codes come from: --otp, a TOTP secret in
+// AGFA_TOTP_SECRET, or else a prompt if stdin is a terminal.
+func otpSource() func(context.Context) (string, error) {
+	switch {
+	case otpCode != "":
+		return func(context.Context) (string, error) { return otpCode, nil }
+	case totpSecret != "":
+		return agfa.TOTP(totpSecret)
+	case isTerminal(os.Stdin):
+		return promptOTP
+	default:
+		return nil
+	}
+}
+
+func promptOTP(ctx context.Context) (string, error) {
+	fmt.Fprint(rootCmd.ErrOrStderr(), "one-time code: ")
+
+	line, err := bufio.NewReader(rootCmd.InOrStdin()).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// probeSession sends a cheap search with nothing but the cached
+// credentials, so that a stale session shows up as ErrSessionExpired
+// rather than triggering a new login.
+func probeSession(ctx context.Context, state agfa.SessionState) error {
+	var opts []func(*agfa.Client)
+	switch {
+	case state.Token != nil:
+		opts = append(opts, agfa.WithAuthenticator(agfa.BearerToken(state.Token.AccessToken)))
+	case authMode == "bearer":
+		opts = append(opts, agfa.WithAuthenticator(agfa.BearerToken(bearerToken)))
+	}
+
+	c := agfa.NewClient(state.BaseUrl, append(clientOpts(), opts...)...)
+	c.Restore(state)
+
+	var res any
+	return c.GetQuery(ctx, "List", url.Values{"_count": {"0"}}, &res)
+}
+
+// sessionPath is the cache file for the current server, auth mode and
+// user, under the per-user cache dir ($XDG_CACHE_HOME on Linux).
+func sessionPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("couldn't find cache dir: %v", err)
+	}
+
+	key := sha256.Sum256([]byte(baseUrl + "\x00" + authMode + "\x00" + user + "\x00" + clientId))
+	return filepath.Join(dir, "agfapi", "sessions", hex.EncodeToString(key[:8])+".json"), nil
+}
+
+// loadSession returns the cached session, or nil if there is none.
+func loadSession() (*cachedSession, error) {
+	path, err := sessionPath()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read session cache: %v", err)
+	}
+
+	var cached cachedSession
+	if err = json.Unmarshal(b, &cached); err != nil {
+		return nil, fmt.Errorf("corrupt session cache %s: %v", path, err)
+	}
+
+	return &cached, nil
+}
+
+// saveSession writes the client's credentials to the session cache. A
+// failure only costs a login next time, so it is logged rather than
+// returned.
+func saveSession() {
+	if noCache {
+		return
+	}
+
+	if err := writeSession(cachedSession{
+		Auth:         authMode,
+		User:         user,
+		ClientId:     clientId,
+		SessionState: client.State(),
+	}); err != nil {
+		log.Printf("couldn't save session: %v\n", err)
+	}
+}
+
+func writeSession(cached cachedSession) error {
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+
+	// CreateTemp makes the file 0600, and the rename keeps concurrent
+	// invocations from reading a half written cache
+	f, err := os.CreateTemp(filepath.Dir(path), ".session-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
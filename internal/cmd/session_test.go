@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/s-hammon/agfapi/pkg/agfa"
+	"github.com/stretchr/testify/require"
+)
+
+// withSessionCache points the session cache at a temp dir and logs in with
+// client credentials against a fake token endpoint, returning the number of
+// tokens it has handed out.
+func withSessionCache(t *testing.T) *atomic.Int32 {
+	t.Helper()
+
+	var logins atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		logins.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token": "t0k3n", "token_type": "Bearer", "expires_in": 3600}`)
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	prevClient, prevBase, prevAuth, prevId, prevSecret, prevToken, prevNoCache :=
+		client, baseUrl, authMode, clientId, clientSecret, tokenUrl, noCache
+	t.Cleanup(func() {
+		client, baseUrl, authMode, clientId, clientSecret, tokenUrl, noCache =
+			prevClient, prevBase, prevAuth, prevId, prevSecret, prevToken, prevNoCache
+	})
+	baseUrl, authMode, clientId, clientSecret, tokenUrl, noCache =
+		ts.URL, "client-credentials", "agfapi", "s3cret", ts.URL+"/token", false
+
+	return &logins
+}
+
+func TestWriteSession_Permissions(t *testing.T) {
+	withSessionCache(t)
+
+	require.NoError(t, writeSession(cachedSession{Auth: authMode, SessionState: agfa.SessionState{BaseUrl: baseUrl}}))
+
+	path, err := sessionPath()
+	require.NoError(t, err)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	info, err = os.Stat(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+	cached, err := loadSession()
+	require.NoError(t, err)
+	require.Equal(t, baseUrl, cached.BaseUrl)
+}
+
+func TestNewClient_CachedSession(t *testing.T) {
+	logins := withSessionCache(t)
+
+	require.NoError(t, newClient(context.Background()))
+	require.EqualValues(t, 1, logins.Load())
+
+	require.NoError(t, newClient(context.Background()))
+	require.EqualValues(t, 1, logins.Load(), "a live cached session must be reused")
+}
+
+func TestNewClient_CorruptSession(t *testing.T) {
+	logins := withSessionCache(t)
+
+	path, err := sessionPath()
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+
+	_, err = loadSession()
+	require.Error(t, err)
+
+	require.NoError(t, newClient(context.Background()))
+	require.EqualValues(t, 1, logins.Load())
+
+	cached, err := loadSession()
+	require.NoError(t, err)
+	require.Equal(t, "t0k3n", cached.Token.AccessToken)
+}
+
+func TestNewClient_ExpiredSession(t *testing.T) {
+	logins := withSessionCache(t)
+
+	require.NoError(t, writeSession(cachedSession{Auth: authMode, ClientId: clientId, SessionState: agfa.SessionState{
+		BaseUrl: baseUrl,
+		Token:   &agfa.Token{AccessToken: "stale", Expiry: time.Now().Add(-time.Hour)},
+		Saved:   time.Now().Add(-2 * time.Hour),
+	}}))
+
+	require.NoError(t, newClient(context.Background()))
+	require.EqualValues(t, 1, logins.Load())
+
+	cached, err := loadSession()
+	require.NoError(t, err)
+	require.Equal(t, "t0k3n", cached.Token.AccessToken)
+}
+
+func TestLogout_All(t *testing.T) {
+	withSessionCache(t)
+
+	require.NoError(t, writeSession(cachedSession{Auth: authMode, SessionState: agfa.SessionState{BaseUrl: baseUrl}}))
+
+	path, err := sessionPath()
+	require.NoError(t, err)
+	sessions := filepath.Dir(path)
+	other := filepath.Join(filepath.Dir(sessions), "other")
+	require.NoError(t, os.WriteFile(other, nil, 0o600))
+
+	prev := logoutAll
+	t.Cleanup(func() { logoutAll = prev })
+	logoutAll = true
+
+	logoutCmd.SetOut(io.Discard)
+	require.NoError(t, logoutCmd.RunE(logoutCmd, nil))
+
+	require.NoDirExists(t, sessions)
+	require.FileExists(t, other)
+}
@@ -1,16 +1,18 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/s-hammon/agfapi/pkg/agfa"
+	"github.com/s-hammon/p"
 	"github.com/spf13/cobra"
 )
 
@@ -30,93 +32,90 @@ var worklistCmd = &cobra.Command{
 }
 
 var (
-	outputPath string
-	out        io.Writer
+	outputPath  string
+	out         io.Writer
+	concurrency int
+	rps         float64
+	strict      bool
+	batchSize   int
+	format      string
+	columns     []string
 )
 
 func init() {
 	rootCmd.AddCommand(worklistCmd)
 
 	worklistCmd.AddCommand(getCmd)
-	worklistCmd.PersistentFlags().StringVarP(&outputPath, "output-filepath", "o", "", "filepath to save results to (JSON)")
+	worklistCmd.PersistentFlags().StringVarP(&outputPath, "output-filepath", "o", "", "filepath to save results to")
+	getCmd.Flags().IntVar(&concurrency, "concurrency", agfa.DefaultConcurrency, "max number of Task/ServiceRequest fetches in flight")
+	getCmd.Flags().IntVar(&batchSize, "batch-size", agfa.DefaultBatchSize, "tasks to look up per search request (1 fetches each one on its own)")
+	getCmd.Flags().BoolVar(&strict, "strict", false, "exit non-zero if any worklist entry couldn't be resolved")
+	getCmd.Flags().Float64Var(&rps, "rps", 0, "max requests per second sent to the server (0 for no limit)")
+	getCmd.Flags().StringVarP(&format, "format", "f", "json", "output format: "+strings.Join(formats, ", "))
+	getCmd.Flags().StringSliceVar(&columns, "columns", nil, "fields to output, by name ("+strings.Join(defaultColumns, ", ")+") or path, e.g. task.owner.display or serviceRequest.code.coding.0.code")
 }
 
 var getCmd = &cobra.Command{
-	Use:     "get [bundle-id]",
-	Args:    cobra.ExactArgs(1),
-	PreRunE: requestPreRun,
+	Use:  "get [bundle-id]",
+	Args: cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkFormat(format); err != nil {
+			return err
+		}
+
+		return requestPreRun(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
-		svcReqs, err := handleGetWorklist(args[0])
-		if err != nil {
+		items, err := handleGetWorklist(cmd.Context(), args[0])
+		if items == nil {
 			return err
 		}
 
-		log.Printf("found %d results\n", len(svcReqs))
-		prettyPrintJson(out, svcReqs)
+		log.Printf("found %d results\n", len(items))
+		if rerr := renderWorklist(out, format, columns, items); rerr != nil {
+			return rerr
+		}
 		if closer, ok := out.(io.WriteCloser); ok {
 			closer.Close()
 		}
-		return nil
+		return err
 	},
 }
 
-func handleGetWorklist(listId string) ([]agfa.ServiceRequest, error) {
-	list, err := client.FetchListById(listId)
-	if err != nil {
-		return nil, fmt.Errorf("couldn't get bundle: %v", err)
-	}
-
-	svcReqs := make([]agfa.ServiceRequest, 0)
-	ch := make(chan agfa.ServiceRequest, len(list.Entry))
-
-	var (
-		taskId string
-		reqId  string
-		wg     sync.WaitGroup
-	)
-
+// handleGetWorklist logs every entry that couldn't be resolved. The returned
+// error is only non-nil for partial failures if --strict is set.
+func handleGetWorklist(ctx context.Context, listId string) ([]agfa.WorklistItem, error) {
 	t1 := time.Now()
-	for _, e := range list.Entry {
-		if e.Item.IsTask() {
-			wg.Go(func() {
-				taskId = e.Item.ExtractTaskId()
-				task, err := client.FetchTaskById(taskId)
-				if err != nil {
-					log.Printf("error fetching task ID %q: %v\n", taskId, err)
-					return
-				}
-				reqId = task.ServiceRequestId()
-				if reqId == "" {
-					log.Printf("no reqId for task %q\n", taskId)
-					return
-				}
-
-				svcReq, err := client.FetchServiceRequestById(reqId)
-				if err != nil {
-					log.Printf("error fetching service request ID %q: %v\n", reqId, err)
-				}
-
-				ch <- svcReq
-			})
+	wl, err := client.ResolveWorklistContext(ctx, listId)
+	log.Printf("elapsed time: %.2fs\n", time.Since(t1).Seconds())
 
-		}
+	entryErrs := agfa.EntryErrors(err)
+	if err != nil && len(entryErrs) == 0 {
+		return nil, fhirError("couldn't resolve worklist", err)
 	}
 
-	go func() {
-		wg.Wait()
-		close(ch)
-		log.Printf("elapsed time: %.2fs\n", time.Since(t1).Seconds())
-	}()
+	for _, e := range entryErrs {
+		log.Println(fhirError(p.Format("couldn't resolve %s", e.Reference), e.Err))
+	}
 
-	for svcReq := range ch {
-		svcReqs = append(svcReqs, svcReq)
+	if len(entryErrs) != 0 {
+		err = fmt.Errorf("%d of %d worklist entries failed", len(entryErrs), len(entryErrs)+len(wl.Items))
+		if !strict {
+			log.Println(err)
+			err = nil
+		}
 	}
 
-	return svcReqs, nil
+	return wl.Items, err
 }
 
+// prettyPrintJson writes obj as indented JSON, scrubbed of PHI if
+// --redact-phi is set.
 func prettyPrintJson(w io.Writer, obj any) {
 	b, _ := json.MarshalIndent(obj, "", "  ")
+	if redactPhi {
+		b = redactor.JSON(b)
+	}
 	fmt.Fprintln(w, string(b))
 }
 
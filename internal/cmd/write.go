@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/s-hammon/agfapi/pkg/agfa"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ifMatch     string
+	force       bool
+	where       []string
+	ifNoneExist []string
+)
+
+var createCmd = &cobra.Command{
+	Use:     "create [file]",
+	Short:   "Create a resource read from a file, or stdin if none is given",
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: requestPreRun,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		body, err := readBody(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		opts, err := writeOpts()
+		if err != nil {
+			return err
+		}
+
+		var res json.RawMessage
+		if err = client.Create(cmd.Context(), json.RawMessage(body), &res, opts...); err != nil {
+			return fhirError("client.Create", err)
+		}
+
+		return printResult(res)
+	},
+}
+
+var updateCmd = &cobra.Command{
+	Use:     "update [file]",
+	Short:   "Update a resource read from a file, or stdin if none is given",
+	Long:    "Update a resource read from a file, or stdin if none is given.\n\nIf the resource carries meta.versionId, the update only goes through if the\nserver still has that version, unless --force is set.",
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: requestPreRun,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		body, err := readBody(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		opts, err := writeOpts()
+		if err != nil {
+			return err
+		}
+
+		var res json.RawMessage
+		if err = client.Update(cmd.Context(), json.RawMessage(body), &res, opts...); err != nil {
+			return fhirError("client.Update", err)
+		}
+
+		return printResult(res)
+	},
+}
+
+var patchCmd = &cobra.Command{
+	Use:     "patch [endpoint] [file]",
+	Short:   "Patch a resource with a JSON Patch or FHIR Patch read from a file, or stdin if none is given",
+	Args:    cobra.RangeArgs(1, 2),
+	PreRunE: requestPreRun,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		body, err := readBody(cmd, args[1:])
+		if err != nil {
+			return err
+		}
+
+		patch, err := parsePatch(body)
+		if err != nil {
+			return err
+		}
+
+		opts, err := writeOpts()
+		if err != nil {
+			return err
+		}
+
+		resourceType, id, _ := strings.Cut(args[0], "/")
+
+		var res json.RawMessage
+		if err = client.Patch(cmd.Context(), resourceType, id, patch, &res, opts...); err != nil {
+			return fhirError("client.Patch", err)
+		}
+
+		return printResult(res)
+	},
+}
+
+var transactionCmd = &cobra.Command{
+	Use:     "transaction [file]",
+	Short:   "Submit a batch or transaction Bundle read from a file, or stdin if none is given",
+	Args:    cobra.MaximumNArgs(1),
+	PreRunE: requestPreRun,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		body, err := readBody(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Submit(cmd.Context(), json.RawMessage(body))
+		if err != nil {
+			return fhirError("client.Submit", err)
+		}
+
+		if err = printResult(resp); err != nil {
+			return err
+		}
+
+		entryErrs := agfa.EntryErrors(resp.Err())
+		for _, e := range entryErrs {
+			log.Println(e)
+		}
+
+		if len(entryErrs) != 0 {
+			return fmt.Errorf("%d of %d bundle entries failed", len(entryErrs), len(resp.Entry))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(createCmd, updateCmd, patchCmd, transactionCmd)
+
+	createCmd.Flags().StringArrayVar(&ifNoneExist, "if-none-exist", []string{}, "only create the resource if none matches this query param (key=value)")
+
+	for _, cmd := range []*cobra.Command{updateCmd, patchCmd} {
+		cmd.Flags().StringVar(&ifMatch, "if-match", "", "only write if the resource is still at this versionId")
+		cmd.Flags().StringArrayVar(&where, "where", []string{}, "address the resource by query param (key=value) instead of by id")
+	}
+	updateCmd.Flags().BoolVar(&force, "force", false, "overwrite the resource whatever its current version")
+}
+
+// readBody reads the file named by args[0], or stdin if there's none or
+// it is "-".
+func readBody(cmd *cobra.Command, args []string) ([]byte, error) {
+	if len(args) == 0 || args[0] == "-" {
+		return io.ReadAll(cmd.InOrStdin())
+	}
+
+	body, err := os.ReadFile(args[0])
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %s: %v", args[0], err)
+	}
+
+	return body, nil
+}
+
+// parsePatch tells a JSON Patch (an array of operations) from a FHIR Patch
+// (a Parameters resource).
+func parsePatch(body []byte) (agfa.Patch, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		var patch agfa.JSONPatch
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return nil, fmt.Errorf("invalid JSON Patch: %v", err)
+		}
+		return patch, nil
+	}
+
+	var patch agfa.FHIRPatch
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, fmt.Errorf("invalid FHIR Patch: %v", err)
+	}
+
+	if patch.ResourceType != "Parameters" {
+		return nil, errors.New("patch must be a JSON Patch array or a FHIR Patch Parameters resource")
+	}
+
+	return patch, nil
+}
+
+func writeOpts() ([]func(*agfa.WriteOptions), error) {
+	opts := make([]func(*agfa.WriteOptions), 0)
+
+	if ifMatch != "" {
+		opts = append(opts, agfa.IfMatch(ifMatch))
+	}
+
+	if force {
+		opts = append(opts, agfa.IgnoreVersion())
+	}
+
+	if len(where) != 0 {
+		query, err := parseQueryParams(where)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, agfa.Where(query))
+	}
+
+	if len(ifNoneExist) != 0 {
+		query, err := parseQueryParams(ifNoneExist)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, agfa.IfNoneExist(query))
+	}
+
+	return opts, nil
+}
+
+// printResult prints res, unless it is an empty response body.
+func printResult(res any) error {
+	if raw, ok := res.(json.RawMessage); !ok || len(raw) != 0 {
+		prettyPrintJson(out, res)
+	}
+
+	if closer, ok := out.(io.WriteCloser); ok {
+		closer.Close()
+	}
+
+	return nil
+}
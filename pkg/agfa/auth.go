@@ -0,0 +1,176 @@
+package agfa
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Authenticator establishes and renews a Client's credentials. Client
+// calls Authenticate from Login, Apply on every outgoing request, and
+// Refresh when the server rejects a request as unauthenticated, after which
+// the request is sent once more. Client serializes calls to Authenticate
+// and Refresh; Apply may be called concurrently.
+type Authenticator interface {
+	// Authenticate obtains fresh credentials for client.
+	Authenticate(ctx context.Context, client *Client) error
+	// Refresh renews credentials the server no longer accepts.
+	Refresh(ctx context.Context, client *Client) error
+	// Apply adds the credentials to req.
+	Apply(client *Client, req *http.Request) error
+}
+
+// WithAuthenticator makes the client authenticate through auth.
+func WithAuthenticator(auth Authenticator) func(*Client) {
+	return func(client *Client) {
+		client.auth = auth
+	}
+}
+
+// Login authenticates the client with its Authenticator. Clients log in
+// lazily on the first rejected request as well, so calling Login is only
+// needed to surface bad credentials early.
+func (client *Client) Login(ctx context.Context) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.auth == nil {
+		return errors.New("no authenticator configured")
+	}
+
+	if err := client.auth.Authenticate(ctx, client); err != nil {
+		return client.redactor.Error(err)
+	}
+	client.sessionGen++
+
+	return nil
+}
+
+// BearerToken authenticates with a fixed access token, e.g. one handed out
+// by an external SSO tool. It cannot be refreshed.
+type BearerToken string
+
+func (t BearerToken) Authenticate(context.Context, *Client) error { return nil }
+
+func (t BearerToken) Refresh(context.Context, *Client) error {
+	return errors.New("static bearer token was rejected")
+}
+
+func (t BearerToken) Apply(client *Client, req *http.Request) error {
+	client.redactor.AddSecret(string(t))
+	req.Header.Set("Authorization", "Bearer "+string(t))
+	return nil
+}
+
+// CookieFile authenticates with the cookies of a browser session exported
+// to a Netscape cookies.txt file, as written by curl or browser extensions.
+// Refresh reads the file again, so a session can be renewed by exporting
+// the cookies once more.
+type CookieFile struct {
+	Path string
+
+	modTime time.Time
+}
+
+func (cf *CookieFile) Authenticate(_ context.Context, client *Client) error {
+	info, err := os.Stat(cf.Path)
+	if err != nil {
+		return fmt.Errorf("cookie file: %v", err)
+	}
+
+	data, err := os.ReadFile(cf.Path)
+	if err != nil {
+		return fmt.Errorf("cookie file: %v", err)
+	}
+
+	cookies, err := parseCookieFile(data)
+	if err != nil {
+		return fmt.Errorf("cookie file %s: %v", cf.Path, err)
+	}
+
+	for _, c := range cookies {
+		u := &url.URL{Scheme: "http", Host: strings.TrimPrefix(c.Domain, "."), Path: c.Path}
+		if c.Secure {
+			u.Scheme = "https"
+		}
+		client.hc.Jar.SetCookies(u, []*http.Cookie{c.Cookie})
+	}
+	cf.modTime = info.ModTime()
+
+	return nil
+}
+
+func (cf *CookieFile) Refresh(ctx context.Context, client *Client) error {
+	info, err := os.Stat(cf.Path)
+	if err != nil {
+		return fmt.Errorf("cookie file: %v", err)
+	}
+
+	if !info.ModTime().After(cf.modTime) {
+		return fmt.Errorf("cookie file %s holds an expired session", cf.Path)
+	}
+
+	return cf.Authenticate(ctx, client)
+}
+
+func (cf *CookieFile) Apply(*Client, *http.Request) error { return nil }
+
+// fileCookie is a cookies.txt entry. Domain keeps the file's spelling,
+// while Cookie.Domain is only set for cookies that match subdomains.
+type fileCookie struct {
+	*http.Cookie
+	Domain string
+}
+
+func parseCookieFile(data []byte) ([]fileCookie, error) {
+	var cookies []fileCookie
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab separated fields, got %d", n, len(fields))
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", n, fields[4])
+		}
+
+		c := &http.Cookie{
+			Name:     fields[5],
+			Value:    fields[6],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		if strings.EqualFold(fields[1], "TRUE") {
+			c.Domain = fields[0]
+		}
+		if expires != 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+
+		cookies = append(cookies, fileCookie{Cookie: c, Domain: fields[0]})
+	}
+
+	return cookies, sc.Err()
+}
@@ -0,0 +1,114 @@
+package agfa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingAuth hands out a new token on every refresh.
+type countingAuth struct {
+	n int
+}
+
+func (a *countingAuth) Authenticate(context.Context, *Client) error {
+	a.n = 1
+	return nil
+}
+
+func (a *countingAuth) Refresh(context.Context, *Client) error {
+	a.n++
+	return nil
+}
+
+func (a *countingAuth) Apply(_ *Client, req *http.Request) error {
+	req.Header.Set("X-Token", fmt.Sprint(a.n))
+	return nil
+}
+
+func TestAuthenticator_Refresh(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"foo": "bar"}`)
+	}))
+	defer ts.Close()
+
+	auth := &countingAuth{}
+	c := NewClient(ts.URL, WithAuthenticator(auth))
+	require.NoError(t, c.Login(context.Background()))
+
+	var got objStruct
+	require.NoError(t, c.Get("Task/1", nil, &got))
+	require.Equal(t, "bar", got.Foo)
+	require.Equal(t, 2, auth.n)
+}
+
+func TestBearerToken(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"foo": "bar"}`)
+	}))
+	defer ts.Close()
+
+	var got objStruct
+	require.NoError(t, NewClient(ts.URL, WithAuthenticator(BearerToken("abc"))).Get("Task/1", nil, &got))
+
+	err := NewClient(ts.URL, WithAuthenticator(BearerToken("nope"))).Get("Task/1", nil, &got)
+	require.ErrorIs(t, err, ErrSessionExpired)
+}
+
+func TestCookieFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("sid")
+		if err != nil || c.Value != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"foo": "bar"}`)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	write := func(sid string) {
+		content := "# Netscape HTTP Cookie File\n\n" +
+			"#HttpOnly_" + u.Hostname() + "\tFALSE\t/\tFALSE\t0\tsid\t" + sid + "\n" +
+			u.Hostname() + "\tFALSE\t/\tFALSE\t" + fmt.Sprint(time.Now().Add(time.Hour).Unix()) + "\tlang\ten\n"
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	write("stale")
+	c := NewClient(ts.URL, WithAuthenticator(&CookieFile{Path: path}))
+	require.NoError(t, c.Login(context.Background()))
+	require.Len(t, c.hc.Jar.Cookies(u), 2)
+
+	// unchanged file can't refresh the session
+	var got objStruct
+	require.ErrorIs(t, c.Get("Task/1", nil, &got), ErrSessionExpired)
+
+	// a fresh export is picked up on the next refresh
+	write("s3cret")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	require.NoError(t, c.Get("Task/1", nil, &got))
+	require.Equal(t, "bar", got.Foo)
+}
+
+func TestParseCookieFile_Invalid(t *testing.T) {
+	_, err := parseCookieFile([]byte("example.com\tTRUE\t/\n"))
+	require.ErrorContains(t, err, "line 1")
+}
@@ -1,9 +1,12 @@
 package agfa
 
 import (
+	"crypto/tls"
 	"net/http"
-	"net/http/cookiejar"
+	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
 const DefaultDomain = "Agility"
@@ -16,26 +19,49 @@ type Client struct {
 	TokenUrl       string
 	ClientId       string
 	RedirectListId string
-	VerifySsl      bool
+	// VerifySsl reports whether the server's certificate is verified. It
+	// is only read by NewClient, so changing it afterwards has no effect:
+	// use WithInsecureSkipVerify to turn verification off.
+	VerifySsl bool
 
 	hc          *http.Client
 	authHeaders map[string]string
+	retry       RetryPolicy
+	limiter     *rateLimiter
+	concurrency int
+	batchSize   int
+	auth        Authenticator
+	tlsConfig   *tls.Config
+	transport   http.RoundTripper
+	middleware  Chain
+	hooks       []Hook
+	redactor    *Redactor
+	proxy       func(*http.Request) (*url.URL, error)
+	timeout     time.Duration
+	userAgent   string
+	optErr      error
+
+	mu         sync.Mutex
+	sessionGen uint64
+	onRelogin  func(error)
 }
 
 func NewClient(url string, opts ...func(*Client)) *Client {
-	jar, _ := cookiejar.New(nil)
-
 	client := &Client{
-		BaseUrl: url,
-		Domain:  DefaultDomain,
+		BaseUrl:   url,
+		Domain:    DefaultDomain,
+		batchSize: DefaultBatchSize,
+		VerifySsl: true,
+		redactor:  &Redactor{},
 		hc: &http.Client{
-			Jar: jar,
+			Jar: newRecordingJar(),
 		},
 	}
 
 	for _, opt := range opts {
 		opt(client)
 	}
+	client.buildTransport()
 
 	return client
 }
@@ -45,14 +71,22 @@ func (client *Client) Base() string {
 	return strings.TrimRight(client.BaseUrl, "/")
 }
 
-// temporarily disable following a redirect for a request
-// returns a function which toggles this back to default
-func (client *Client) disableCheckRedirect() func() {
-	client.hc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		return http.ErrUseLastResponse
+// WithReloginHook registers fn to be called every time the client refreshes
+// its credentials after the server rejected them. err is nil if the refresh
+// succeeded.
+func WithReloginHook(fn func(err error)) func(*Client) {
+	return func(client *Client) {
+		client.onRelogin = fn
 	}
+}
 
-	return func() {
-		client.hc.CheckRedirect = nil
+// noRedirect returns a copy of the http client (sharing its cookie jar)
+// which does not follow redirects.
+func (client *Client) noRedirect() *http.Client {
+	hc := *client.hc
+	hc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
 	}
+
+	return &hc
 }
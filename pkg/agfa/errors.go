@@ -0,0 +1,120 @@
+package agfa
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/s-hammon/p"
+)
+
+// ErrSessionExpired is returned when the server bounces a request to the
+// identity provider and the client could not log in again.
+var ErrSessionExpired = errors.New("agfa: session expired")
+
+// ErrNotModified is returned by conditional reads when the resource hasn't
+// changed since the given version or time.
+var ErrNotModified = errors.New("agfa: not modified")
+
+// Error is returned whenever the FHIR server answers with an unexpected
+// status code. Use errors.As to inspect it, or one of the IsXxx helpers.
+type Error struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// Outcome is the decoded OperationOutcome, if the server sent one.
+	Outcome *OperationOutcome
+
+	// redactor scrubs the message; the fields are left as they are.
+	redactor *Redactor
+}
+
+func (client *Client) newError(resp *http.Response, body []byte) *Error {
+	e := newError(resp, body)
+	e.redactor = client.redactor
+	return e
+}
+
+func newError(resp *http.Response, body []byte) *Error {
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}
+
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.Url = resp.Request.URL.String()
+	}
+
+	var oo OperationOutcome
+	if err := json.Unmarshal(body, &oo); err == nil && oo.ResourceType == "OperationOutcome" {
+		e.Outcome = &oo
+	}
+
+	return e
+}
+
+func (e *Error) Error() string {
+	detail := string(e.Body)
+	if e.Outcome != nil && len(e.Outcome.Issue) != 0 {
+		issues := make([]string, len(e.Outcome.Issue))
+		for i, issue := range e.Outcome.Issue {
+			issues[i] = issue.String()
+		}
+		detail = strings.Join(issues, "; ")
+	}
+
+	return p.Format("FHIR %s failed: %d %s", e.Method, e.StatusCode, e.redactor.String(detail))
+}
+
+func hasStatus(err error, codes ...int) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	for _, code := range codes {
+		if e.StatusCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsNotFound reports whether err is a FHIR 404 or 410.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound, http.StatusGone)
+}
+
+// IsUnauthorized reports whether err is a FHIR 401.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsForbidden reports whether err is a FHIR 403.
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+// IsRateLimited reports whether err is a FHIR 429.
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// LoginError is returned when the identity provider rejects a login, with
+// the message it displayed, e.g. "Invalid username or password." or
+// "Account is disabled, contact your administrator."
+type LoginError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *LoginError) Error() string {
+	return "login rejected: " + e.Message
+}
@@ -0,0 +1,74 @@
+package agfa
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientGet_OperationOutcome(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/fhir+json")
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{
+			"resourceType": "OperationOutcome",
+			"issue": [{
+				"severity": "error",
+				"code": "not-found",
+				"diagnostics": "Resource Task/123 is not known"
+			}]
+		}`)
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+
+	var got objStruct
+	err := client.Get("Task/123", nil, &got)
+	require.Error(t, err)
+	require.True(t, IsNotFound(err))
+	require.False(t, IsUnauthorized(err))
+
+	var fe *Error
+	require.True(t, errors.As(fmt.Errorf("wrapped: %w", err), &fe))
+	require.Equal(t, http.StatusNotFound, fe.StatusCode)
+	require.Equal(t, http.MethodGet, fe.Method)
+	require.Equal(t, ts.URL+"/Task/123", fe.Url)
+	require.Equal(t, "application/fhir+json", fe.Header.Get("Content-Type"))
+	require.NotNil(t, fe.Outcome)
+	require.Len(t, fe.Outcome.Issue, 1)
+	require.Equal(t, "not-found", fe.Outcome.Issue[0].Code)
+	require.Equal(t, "FHIR GET failed: 404 error [not-found]: Resource Task/123 is not known", err.Error())
+}
+
+func TestClientGet_Unauthorized(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+
+	var got objStruct
+	err := client.Get("List/1", nil, &got)
+	require.True(t, IsUnauthorized(err))
+	require.False(t, IsNotFound(err))
+	require.False(t, IsNotFound(errors.New("plain")))
+}
+
+func TestOperationOutcomeIssue_String(t *testing.T) {
+	issue := OperationOutcomeIssue{
+		Severity: "warning",
+		Code:     "processing",
+		Details:  Code{Coding: []Coding{{Display: "fallback"}}},
+	}
+	require.Equal(t, "warning [processing]: fallback", issue.String())
+
+	issue.Details.Text = "text"
+	require.Equal(t, "warning [processing]: text", issue.String())
+}
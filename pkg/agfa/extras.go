@@ -0,0 +1,548 @@
+package agfa
+
+import (
+	"bytes"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Extras holds the members of a resource's or element's JSON that this
+// package doesn't model, such as notes, contained resources or an
+// identifier's period, so that they survive a decode/encode round trip.
+type Extras map[string]json.RawMessage
+
+// Get decodes the extra member name into v, reporting whether it was present.
+func (e Extras) Get(name string, v any) (bool, error) {
+	raw, ok := e[name]
+	if !ok {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(raw, v)
+}
+
+// jsonNames caches the JSON member names of each struct type.
+var jsonNames sync.Map
+
+func knownNames(t reflect.Type) []string {
+	if names, ok := jsonNames.Load(t); ok {
+		return names.([]string)
+	}
+
+	names := make([]string, 0, t.NumField())
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+
+	jsonNames.Store(t, names)
+	return names
+}
+
+// unmarshalWithExtras decodes data into v, a pointer to a struct, and stores
+// every member v has no field for in extras.
+func unmarshalWithExtras(data []byte, v any, extras *Extras) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	// encoding/json matches member names case-insensitively, so do the same
+	names := knownNames(reflect.TypeOf(v).Elem())
+	maps.DeleteFunc(members, func(k string, _ json.RawMessage) bool {
+		return slices.ContainsFunc(names, func(name string) bool {
+			return strings.EqualFold(name, k)
+		})
+	})
+
+	*extras = nil
+	if len(members) != 0 {
+		*extras = members
+	}
+
+	return nil
+}
+
+// marshalWithExtras encodes v and appends extras after its modeled members,
+// skipping any extra which would collide with one of them.
+func marshalWithExtras(v any, extras Extras) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extras) == 0 {
+		return data, err
+	}
+
+	names := knownNames(reflect.TypeOf(v))
+
+	buf := bytes.NewBuffer(data[:len(data)-1])
+	sep := len(data) > 2
+	for _, k := range slices.Sorted(maps.Keys(extras)) {
+		if slices.Contains(names, k) {
+			continue
+		}
+
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+
+		if sep {
+			buf.WriteByte(',')
+		}
+		sep = true
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(extras[k])
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func (l *List) UnmarshalJSON(data []byte) error {
+	type list List
+	return unmarshalWithExtras(data, (*list)(l), &l.Extras)
+}
+
+func (l List) MarshalJSON() ([]byte, error) {
+	type list List
+	return marshalWithExtras(list(l), l.Extras)
+}
+
+func (b *Bundle) UnmarshalJSON(data []byte) error {
+	type bundle Bundle
+	return unmarshalWithExtras(data, (*bundle)(b), &b.Extras)
+}
+
+func (b Bundle) MarshalJSON() ([]byte, error) {
+	type bundle Bundle
+	return marshalWithExtras(bundle(b), b.Extras)
+}
+
+func (b *BundleEntryResource) UnmarshalJSON(data []byte) error {
+	type bundleEntryResource BundleEntryResource
+	return unmarshalWithExtras(data, (*bundleEntryResource)(b), &b.Extras)
+}
+
+func (b BundleEntryResource) MarshalJSON() ([]byte, error) {
+	type bundleEntryResource BundleEntryResource
+	return marshalWithExtras(bundleEntryResource(b), b.Extras)
+}
+
+func (t *Task) UnmarshalJSON(data []byte) error {
+	type task Task
+	return unmarshalWithExtras(data, (*task)(t), &t.Extras)
+}
+
+func (t Task) MarshalJSON() ([]byte, error) {
+	type task Task
+	return marshalWithExtras(task(t), t.Extras)
+}
+
+func (s *ServiceRequest) UnmarshalJSON(data []byte) error {
+	type serviceRequest ServiceRequest
+	return unmarshalWithExtras(data, (*serviceRequest)(s), &s.Extras)
+}
+
+func (s ServiceRequest) MarshalJSON() ([]byte, error) {
+	type serviceRequest ServiceRequest
+	return marshalWithExtras(serviceRequest(s), s.Extras)
+}
+
+func (o *OperationOutcome) UnmarshalJSON(data []byte) error {
+	type operationOutcome OperationOutcome
+	return unmarshalWithExtras(data, (*operationOutcome)(o), &o.Extras)
+}
+
+func (o OperationOutcome) MarshalJSON() ([]byte, error) {
+	type operationOutcome OperationOutcome
+	return marshalWithExtras(operationOutcome(o), o.Extras)
+}
+
+func (p *Patient) UnmarshalJSON(data []byte) error {
+	type patient Patient
+	return unmarshalWithExtras(data, (*patient)(p), &p.Extras)
+}
+
+func (p Patient) MarshalJSON() ([]byte, error) {
+	type patient Patient
+	return marshalWithExtras(patient(p), p.Extras)
+}
+
+func (e *Encounter) UnmarshalJSON(data []byte) error {
+	type encounter Encounter
+	return unmarshalWithExtras(data, (*encounter)(e), &e.Extras)
+}
+
+func (e Encounter) MarshalJSON() ([]byte, error) {
+	type encounter Encounter
+	return marshalWithExtras(encounter(e), e.Extras)
+}
+
+func (i *ImagingStudy) UnmarshalJSON(data []byte) error {
+	type imagingStudy ImagingStudy
+	return unmarshalWithExtras(data, (*imagingStudy)(i), &i.Extras)
+}
+
+func (i ImagingStudy) MarshalJSON() ([]byte, error) {
+	type imagingStudy ImagingStudy
+	return marshalWithExtras(imagingStudy(i), i.Extras)
+}
+
+func (p *Practitioner) UnmarshalJSON(data []byte) error {
+	type practitioner Practitioner
+	return unmarshalWithExtras(data, (*practitioner)(p), &p.Extras)
+}
+
+func (p Practitioner) MarshalJSON() ([]byte, error) {
+	type practitioner Practitioner
+	return marshalWithExtras(practitioner(p), p.Extras)
+}
+
+func (o *Organization) UnmarshalJSON(data []byte) error {
+	type organization Organization
+	return unmarshalWithExtras(data, (*organization)(o), &o.Extras)
+}
+
+func (o Organization) MarshalJSON() ([]byte, error) {
+	type organization Organization
+	return marshalWithExtras(organization(o), o.Extras)
+}
+
+func (d *DiagnosticReport) UnmarshalJSON(data []byte) error {
+	type diagnosticReport DiagnosticReport
+	return unmarshalWithExtras(data, (*diagnosticReport)(d), &d.Extras)
+}
+
+func (d DiagnosticReport) MarshalJSON() ([]byte, error) {
+	type diagnosticReport DiagnosticReport
+	return marshalWithExtras(diagnosticReport(d), d.Extras)
+}
+
+func (o *Observation) UnmarshalJSON(data []byte) error {
+	type observation Observation
+	return unmarshalWithExtras(data, (*observation)(o), &o.Extras)
+}
+
+func (o Observation) MarshalJSON() ([]byte, error) {
+	type observation Observation
+	return marshalWithExtras(observation(o), o.Extras)
+}
+
+// Elements nested in resources keep their unmodeled members too, e.g. an
+// identifier's use or a reference's type.
+
+func (b *BundleEntrySearch) UnmarshalJSON(data []byte) error {
+	type bundleEntrySearch BundleEntrySearch
+	return unmarshalWithExtras(data, (*bundleEntrySearch)(b), &b.Extras)
+}
+
+func (b BundleEntrySearch) MarshalJSON() ([]byte, error) {
+	type bundleEntrySearch BundleEntrySearch
+	return marshalWithExtras(bundleEntrySearch(b), b.Extras)
+}
+
+func (b *BundleLink) UnmarshalJSON(data []byte) error {
+	type bundleLink BundleLink
+	return unmarshalWithExtras(data, (*bundleLink)(b), &b.Extras)
+}
+
+func (b BundleLink) MarshalJSON() ([]byte, error) {
+	type bundleLink BundleLink
+	return marshalWithExtras(bundleLink(b), b.Extras)
+}
+
+func (b *BundleEntry) UnmarshalJSON(data []byte) error {
+	type bundleEntry BundleEntry
+	return unmarshalWithExtras(data, (*bundleEntry)(b), &b.Extras)
+}
+
+func (b BundleEntry) MarshalJSON() ([]byte, error) {
+	type bundleEntry BundleEntry
+	return marshalWithExtras(bundleEntry(b), b.Extras)
+}
+
+func (r *ResourceMeta) UnmarshalJSON(data []byte) error {
+	type resourceMeta ResourceMeta
+	return unmarshalWithExtras(data, (*resourceMeta)(r), &r.Extras)
+}
+
+func (r ResourceMeta) MarshalJSON() ([]byte, error) {
+	type resourceMeta ResourceMeta
+	return marshalWithExtras(resourceMeta(r), r.Extras)
+}
+
+func (r *ResourceIdentifier) UnmarshalJSON(data []byte) error {
+	type resourceIdentifier ResourceIdentifier
+	return unmarshalWithExtras(data, (*resourceIdentifier)(r), &r.Extras)
+}
+
+func (r ResourceIdentifier) MarshalJSON() ([]byte, error) {
+	type resourceIdentifier ResourceIdentifier
+	return marshalWithExtras(resourceIdentifier(r), r.Extras)
+}
+
+func (u *UrlExtensionIdentifierType) UnmarshalJSON(data []byte) error {
+	type urlExtensionIdentifierType UrlExtensionIdentifierType
+	return unmarshalWithExtras(data, (*urlExtensionIdentifierType)(u), &u.Extras)
+}
+
+func (u UrlExtensionIdentifierType) MarshalJSON() ([]byte, error) {
+	type urlExtensionIdentifierType UrlExtensionIdentifierType
+	return marshalWithExtras(urlExtensionIdentifierType(u), u.Extras)
+}
+
+func (u *UrlExtensionIdentifierTypeCoding) UnmarshalJSON(data []byte) error {
+	type urlExtensionIdentifierTypeCoding UrlExtensionIdentifierTypeCoding
+	return unmarshalWithExtras(data, (*urlExtensionIdentifierTypeCoding)(u), &u.Extras)
+}
+
+func (u UrlExtensionIdentifierTypeCoding) MarshalJSON() ([]byte, error) {
+	type urlExtensionIdentifierTypeCoding UrlExtensionIdentifierTypeCoding
+	return marshalWithExtras(urlExtensionIdentifierTypeCoding(u), u.Extras)
+}
+
+func (c *Coding) UnmarshalJSON(data []byte) error {
+	type coding Coding
+	return unmarshalWithExtras(data, (*coding)(c), &c.Extras)
+}
+
+func (c Coding) MarshalJSON() ([]byte, error) {
+	type coding Coding
+	return marshalWithExtras(coding(c), c.Extras)
+}
+
+func (c *Code) UnmarshalJSON(data []byte) error {
+	type code Code
+	return unmarshalWithExtras(data, (*code)(c), &c.Extras)
+}
+
+func (c Code) MarshalJSON() ([]byte, error) {
+	type code Code
+	return marshalWithExtras(code(c), c.Extras)
+}
+
+func (l *ListEntry) UnmarshalJSON(data []byte) error {
+	type listEntry ListEntry
+	return unmarshalWithExtras(data, (*listEntry)(l), &l.Extras)
+}
+
+func (l ListEntry) MarshalJSON() ([]byte, error) {
+	type listEntry ListEntry
+	return marshalWithExtras(listEntry(l), l.Extras)
+}
+
+func (l *ListEntryItem) UnmarshalJSON(data []byte) error {
+	type listEntryItem ListEntryItem
+	return unmarshalWithExtras(data, (*listEntryItem)(l), &l.Extras)
+}
+
+func (l ListEntryItem) MarshalJSON() ([]byte, error) {
+	type listEntryItem ListEntryItem
+	return marshalWithExtras(listEntryItem(l), l.Extras)
+}
+
+func (r *Reference) UnmarshalJSON(data []byte) error {
+	type reference Reference
+	return unmarshalWithExtras(data, (*reference)(r), &r.Extras)
+}
+
+func (r Reference) MarshalJSON() ([]byte, error) {
+	type reference Reference
+	return marshalWithExtras(reference(r), r.Extras)
+}
+
+func (o *OperationOutcomeIssue) UnmarshalJSON(data []byte) error {
+	type operationOutcomeIssue OperationOutcomeIssue
+	return unmarshalWithExtras(data, (*operationOutcomeIssue)(o), &o.Extras)
+}
+
+func (o OperationOutcomeIssue) MarshalJSON() ([]byte, error) {
+	type operationOutcomeIssue OperationOutcomeIssue
+	return marshalWithExtras(operationOutcomeIssue(o), o.Extras)
+}
+
+func (e *EncounterParticipant) UnmarshalJSON(data []byte) error {
+	type encounterParticipant EncounterParticipant
+	return unmarshalWithExtras(data, (*encounterParticipant)(e), &e.Extras)
+}
+
+func (e EncounterParticipant) MarshalJSON() ([]byte, error) {
+	type encounterParticipant EncounterParticipant
+	return marshalWithExtras(encounterParticipant(e), e.Extras)
+}
+
+func (e *EncounterLocation) UnmarshalJSON(data []byte) error {
+	type encounterLocation EncounterLocation
+	return unmarshalWithExtras(data, (*encounterLocation)(e), &e.Extras)
+}
+
+func (e EncounterLocation) MarshalJSON() ([]byte, error) {
+	type encounterLocation EncounterLocation
+	return marshalWithExtras(encounterLocation(e), e.Extras)
+}
+
+func (i *ImagingStudySeries) UnmarshalJSON(data []byte) error {
+	type imagingStudySeries ImagingStudySeries
+	return unmarshalWithExtras(data, (*imagingStudySeries)(i), &i.Extras)
+}
+
+func (i ImagingStudySeries) MarshalJSON() ([]byte, error) {
+	type imagingStudySeries ImagingStudySeries
+	return marshalWithExtras(imagingStudySeries(i), i.Extras)
+}
+
+func (i *ImagingStudyInstance) UnmarshalJSON(data []byte) error {
+	type imagingStudyInstance ImagingStudyInstance
+	return unmarshalWithExtras(data, (*imagingStudyInstance)(i), &i.Extras)
+}
+
+func (i ImagingStudyInstance) MarshalJSON() ([]byte, error) {
+	type imagingStudyInstance ImagingStudyInstance
+	return marshalWithExtras(imagingStudyInstance(i), i.Extras)
+}
+
+func (o *ObservationComponent) UnmarshalJSON(data []byte) error {
+	type observationComponent ObservationComponent
+	return unmarshalWithExtras(data, (*observationComponent)(o), &o.Extras)
+}
+
+func (o ObservationComponent) MarshalJSON() ([]byte, error) {
+	type observationComponent ObservationComponent
+	return marshalWithExtras(observationComponent(o), o.Extras)
+}
+
+func (h *HumanName) UnmarshalJSON(data []byte) error {
+	type humanName HumanName
+	return unmarshalWithExtras(data, (*humanName)(h), &h.Extras)
+}
+
+func (h HumanName) MarshalJSON() ([]byte, error) {
+	type humanName HumanName
+	return marshalWithExtras(humanName(h), h.Extras)
+}
+
+func (c *ContactPoint) UnmarshalJSON(data []byte) error {
+	type contactPoint ContactPoint
+	return unmarshalWithExtras(data, (*contactPoint)(c), &c.Extras)
+}
+
+func (c ContactPoint) MarshalJSON() ([]byte, error) {
+	type contactPoint ContactPoint
+	return marshalWithExtras(contactPoint(c), c.Extras)
+}
+
+func (a *Address) UnmarshalJSON(data []byte) error {
+	type address Address
+	return unmarshalWithExtras(data, (*address)(a), &a.Extras)
+}
+
+func (a Address) MarshalJSON() ([]byte, error) {
+	type address Address
+	return marshalWithExtras(address(a), a.Extras)
+}
+
+func (p *Period) UnmarshalJSON(data []byte) error {
+	type period Period
+	return unmarshalWithExtras(data, (*period)(p), &p.Extras)
+}
+
+func (p Period) MarshalJSON() ([]byte, error) {
+	type period Period
+	return marshalWithExtras(period(p), p.Extras)
+}
+
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	type quantity Quantity
+	return unmarshalWithExtras(data, (*quantity)(q), &q.Extras)
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	type quantity Quantity
+	return marshalWithExtras(quantity(q), q.Extras)
+}
+
+func (a *Attachment) UnmarshalJSON(data []byte) error {
+	type attachment Attachment
+	return unmarshalWithExtras(data, (*attachment)(a), &a.Extras)
+}
+
+func (a Attachment) MarshalJSON() ([]byte, error) {
+	type attachment Attachment
+	return marshalWithExtras(attachment(a), a.Extras)
+}
+
+func (a *Annotation) UnmarshalJSON(data []byte) error {
+	type annotation Annotation
+	return unmarshalWithExtras(data, (*annotation)(a), &a.Extras)
+}
+
+func (a Annotation) MarshalJSON() ([]byte, error) {
+	type annotation Annotation
+	return marshalWithExtras(annotation(a), a.Extras)
+}
+
+func (r *Range) UnmarshalJSON(data []byte) error {
+	type rangeValue Range
+	return unmarshalWithExtras(data, (*rangeValue)(r), &r.Extras)
+}
+
+func (r Range) MarshalJSON() ([]byte, error) {
+	type rangeValue Range
+	return marshalWithExtras(rangeValue(r), r.Extras)
+}
+
+func (r *Ratio) UnmarshalJSON(data []byte) error {
+	type ratio Ratio
+	return unmarshalWithExtras(data, (*ratio)(r), &r.Extras)
+}
+
+func (r Ratio) MarshalJSON() ([]byte, error) {
+	type ratio Ratio
+	return marshalWithExtras(ratio(r), r.Extras)
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	type money Money
+	return unmarshalWithExtras(data, (*money)(m), &m.Extras)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	type money Money
+	return marshalWithExtras(money(m), m.Extras)
+}
+
+func (b *BundleEntryRequest) UnmarshalJSON(data []byte) error {
+	type bundleEntryRequest BundleEntryRequest
+	return unmarshalWithExtras(data, (*bundleEntryRequest)(b), &b.Extras)
+}
+
+func (b BundleEntryRequest) MarshalJSON() ([]byte, error) {
+	type bundleEntryRequest BundleEntryRequest
+	return marshalWithExtras(bundleEntryRequest(b), b.Extras)
+}
+
+func (b *BundleEntryResponse) UnmarshalJSON(data []byte) error {
+	type bundleEntryResponse BundleEntryResponse
+	return unmarshalWithExtras(data, (*bundleEntryResponse)(b), &b.Extras)
+}
+
+func (b BundleEntryResponse) MarshalJSON() ([]byte, error) {
+	type bundleEntryResponse BundleEntryResponse
+	return marshalWithExtras(bundleEntryResponse(b), b.Extras)
+}
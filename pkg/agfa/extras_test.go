@@ -0,0 +1,123 @@
+package agfa
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtras_RoundTrip(t *testing.T) {
+	in := `{
+		"resourceType": "ServiceRequest",
+		"id": "sr1",
+		"status": "active",
+		"code": {"coding": [{"system": "http://loinc.org", "code": "24727-0", "display": "CT HEAD"}]},
+		"subject": {"reference": "Patient/1", "display": "DOE^JANE"},
+		"reasonCode": [{"text": "headache"}],
+		"note": [{"text": "claustrophobic"}],
+		"contained": [{"resourceType": "Patient", "id": "p"}],
+		"extension": [{"url": "http://example.com/ext", "valueString": "x"}]
+	}`
+
+	var svcReq ServiceRequest
+	require.NoError(t, json.Unmarshal([]byte(in), &svcReq))
+	require.Equal(t, "sr1", svcReq.Id)
+	require.Equal(t, "DOE^JANE", svcReq.Subject.Display)
+	require.Len(t, svcReq.Extras, 3)
+	require.NotContains(t, svcReq.Extras, "status")
+	require.NotContains(t, svcReq.Extras, "extension")
+
+	var notes []Annotation
+	ok, err := svcReq.Extras.Get("note", &notes)
+	require.True(t, ok)
+	require.NoError(t, err)
+	require.Equal(t, "claustrophobic", notes[0].Text)
+
+	out, err := json.Marshal(svcReq)
+	require.NoError(t, err)
+	require.JSONEq(t, in, string(out))
+
+	// modeled fields still win over the extras
+	svcReq.Status = "completed"
+	out, err = json.Marshal(svcReq)
+	require.NoError(t, err)
+	require.Contains(t, string(out), `"status":"completed"`)
+
+	// zero, false and trailing decimal zeros are values too
+	in = `{
+		"resourceType": "Patient",
+		"active": false,
+		"telecom": [{"system": "phone", "rank": 0}]
+	}`
+
+	var patient Patient
+	require.NoError(t, json.Unmarshal([]byte(in), &patient))
+	out, err = json.Marshal(patient)
+	require.NoError(t, err)
+	require.JSONEq(t, in, string(out))
+
+	in = `{"resourceType":"Observation","valueQuantity":{"value":1.50,"unit":"mg"},"component":[{"valueQuantity":{"value":0}}]}`
+
+	var obs Observation
+	require.NoError(t, json.Unmarshal([]byte(in), &obs))
+	out, err = json.Marshal(obs)
+	require.NoError(t, err)
+	require.Equal(t, in, string(out))
+}
+
+func TestExtras_NoneLeft(t *testing.T) {
+	var task Task
+	require.NoError(t, json.Unmarshal([]byte(`{"resourceType": "Task", "id": "1"}`), &task))
+	require.Nil(t, task.Extras)
+
+	out, err := json.Marshal(task)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"resourceType": "Task", "id": "1"}`, string(out))
+
+	out, err = json.Marshal(Task{Extras: Extras{"note": json.RawMessage(`[]`)}})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"note": []}`, string(out))
+}
+
+func TestExtras_NestedRoundTrip(t *testing.T) {
+	in := `{
+		"resourceType": "Task",
+		"id": "t1",
+		"identifier": [{
+			"use": "usual",
+			"type": {"coding": [{"code": "ACSN", "version": "2"}], "text": "Accession"},
+			"value": "A1"
+		}],
+		"code": {"coding": [{"system": "http://example.com", "code": "read", "version": "1.0"}]},
+		"for": {"reference": "Patient/1", "type": "Patient", "identifier": {"value": "MRN1"}},
+		"input": [{
+			"id": "in1",
+			"type": {"text": "order"},
+			"valueReference": {"reference": "ServiceRequest/sr1"},
+			"_valueReference": {"extension": [{"url": "http://example.com/x", "valueString": "y"}]}
+		}],
+		"extension": [{"url": "http://example.com/ext", "id": "e1", "_valueString": {"id": "v"}, "valueString": "x"}]
+	}`
+
+	var task Task
+	require.NoError(t, json.Unmarshal([]byte(in), &task))
+	require.Nil(t, task.Extras)
+	require.Contains(t, task.Identifier[0].Extras, "use")
+	require.Contains(t, task.For.Extras, "identifier")
+	require.Contains(t, task.Input[0].Extras, "_valueReference")
+	require.NotContains(t, task.Input[0].Extras, "valueReference")
+	require.Equal(t, "sr1", task.ServiceRequestId())
+
+	out, err := json.Marshal(task)
+	require.NoError(t, err)
+	require.JSONEq(t, in, string(out))
+
+	var params Parameters
+	in = `{"resourceType": "Parameters", "parameter": [{"name": "a", "id": "p1", "valueCode": "x"}]}`
+	require.NoError(t, json.Unmarshal([]byte(in), &params))
+	require.Equal(t, "Code", params.Parameter[0].Value.Type)
+	out, err = json.Marshal(params)
+	require.NoError(t, err)
+	require.JSONEq(t, in, string(out))
+}
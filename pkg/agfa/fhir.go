@@ -1,34 +1,44 @@
 package agfa
 
 import (
+	"encoding/json"
 	"strings"
+	"time"
 
 	"github.com/s-hammon/p"
 )
 
 type List struct {
-	Code         Code
-	Entry        []ListEntry
-	Extension    []Extension
-	Id           string
-	Identifier   []ResourceIdentifier
-	Meta         ResourceMeta
-	Mode         string
-	ResourceType string
-	Status       string
-	Title        string
+	Code         Code                 `json:"code,omitzero"`
+	Entry        []ListEntry          `json:"entry,omitzero"`
+	Extension    Extensions           `json:"extension,omitzero"`
+	Id           string               `json:"id,omitzero"`
+	Identifier   []ResourceIdentifier `json:"identifier,omitzero"`
+	Meta         ResourceMeta         `json:"meta,omitzero"`
+	Mode         string               `json:"mode,omitzero"`
+	ResourceType string               `json:"resourceType,omitzero"`
+	Status       string               `json:"status,omitzero"`
+	Title        string               `json:"title,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 type Bundle struct {
-	ResourceType string
-	Type         string
-	Total        int
-	Link         []BundleLink
-	Entry        []BundleEntry
+	ResourceType string        `json:"resourceType,omitzero"`
+	Type         string        `json:"type,omitzero"`
+	Total        *int          `json:"total,omitzero"`
+	Link         []BundleLink  `json:"link,omitzero"`
+	Entry        []BundleEntry `json:"entry,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 func (b Bundle) String() string {
-	return p.Format("resource=%s, type=%s, total=%d\n", b.ResourceType, b.Type, b.Total)
+	if b.Total == nil {
+		return p.Format("resource=%s, type=%s\n", b.ResourceType, b.Type)
+	}
+
+	return p.Format("resource=%s, type=%s, total=%d\n", b.ResourceType, b.Type, *b.Total)
 }
 
 func (b Bundle) Entries() []ListEntry {
@@ -41,69 +51,138 @@ func (b Bundle) Entries() []ListEntry {
 	return nil
 }
 
+// rawBundle is a Bundle whose entry resources are left undecoded, for
+// search results mixing several resource types.
+type rawBundle struct {
+	ResourceType string        `json:"resourceType,omitzero"`
+	Type         string        `json:"type,omitzero"`
+	Total        *int          `json:"total,omitzero"`
+	Link         []BundleLink  `json:"link,omitzero"`
+	Entry        []SearchEntry `json:"entry,omitzero"`
+}
+
+// SearchEntry is a single search result whose resource is kept as raw JSON,
+// since one result set can mix resource types.
+type SearchEntry struct {
+	FullUrl  string            `json:"fullUrl,omitzero"`
+	Resource json.RawMessage   `json:"resource,omitzero"`
+	Search   BundleEntrySearch `json:"search,omitzero"`
+}
+
+// ResourceType peeks at the entry's resourceType without decoding the rest.
+func (e SearchEntry) ResourceType() string {
+	var r struct{ ResourceType string }
+	if err := json.Unmarshal(e.Resource, &r); err != nil {
+		return ""
+	}
+
+	return r.ResourceType
+}
+
+// Decode unmarshals the entry's resource into v.
+func (e SearchEntry) Decode(v any) error {
+	return json.Unmarshal(e.Resource, v)
+}
+
+type BundleEntrySearch struct {
+	Mode  string      `json:"mode,omitzero"`
+	Score json.Number `json:"score,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+// Next returns the url of the next page of results, if any.
+func (b Bundle) Next() string {
+	return nextLink(b.Link)
+}
+
+func nextLink(links []BundleLink) string {
+	for _, link := range links {
+		if link.Relation == "next" {
+			return link.Url
+		}
+	}
+
+	return ""
+}
+
 type BundleLink struct {
-	Relation string
-	Url      string
+	Relation string `json:"relation,omitzero"`
+	Url      string `json:"url,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 type BundleEntry struct {
-	FullUrl  string
-	Resource BundleEntryResource
+	FullUrl  string              `json:"fullUrl,omitzero"`
+	Resource BundleEntryResource `json:"resource,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 type BundleEntryResource struct {
-	ResourceType string
-	Id           string
-	Meta         ResourceMeta
-	Extension    []UrlExtension
-	Identifier   []ResourceIdentifier
-	Status       string
-	Mode         string
-	Title        string
-	Code         Code
-	Entry        []ListEntry
+	ResourceType string               `json:"resourceType,omitzero"`
+	Id           string               `json:"id,omitzero"`
+	Meta         ResourceMeta         `json:"meta,omitzero"`
+	Extension    Extensions           `json:"extension,omitzero"`
+	Identifier   []ResourceIdentifier `json:"identifier,omitzero"`
+	Status       string               `json:"status,omitzero"`
+	Mode         string               `json:"mode,omitzero"`
+	Title        string               `json:"title,omitzero"`
+	Code         Code                 `json:"code,omitzero"`
+	Entry        []ListEntry          `json:"entry,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 type ResourceMeta struct {
-	Profile []string
-}
-
-type UrlExtension struct {
-	Url       string
-	ValueCode string
-	Extension []Extension
+	VersionId   string   `json:"versionId,omitzero"`
+	LastUpdated string   `json:"lastUpdated,omitzero"`
+	Source      string   `json:"source,omitzero"`
+	Profile     []string `json:"profile,omitzero"`
+	Security    []Coding `json:"security,omitzero"`
+	Tag         []Coding `json:"tag,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
-type Extension struct {
-	Url          string
-	ValueInteger int
-	ValueBoolean bool
+// Updated parses LastUpdated, returning the zero time if it is unset or
+// malformed.
+func (meta ResourceMeta) Updated() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, meta.LastUpdated)
+	return t
 }
 
 type ResourceIdentifier struct {
-	Type   UrlExtensionIdentifierType
-	System string
-	Value  string
+	Type   UrlExtensionIdentifierType `json:"type,omitzero"`
+	System string                     `json:"system,omitzero"`
+	Value  string                     `json:"value,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 type UrlExtensionIdentifierType struct {
-	Coding []UrlExtensionIdentifierTypeCoding
+	Coding []UrlExtensionIdentifierTypeCoding `json:"coding,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 type UrlExtensionIdentifierTypeCoding struct {
-	System  string
-	Code    string
-	Display string
+	System  string `json:"system,omitzero"`
+	Code    string `json:"code,omitzero"`
+	Display string `json:"display,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 type ListResource struct {
-	ResourceType string
-	Id           string
-	Title        string
-	Status       string
-	Mode         string
-	Code         Code
-	Entry        []ListEntry
+	ResourceType string      `json:"resourceType,omitzero"`
+	Id           string      `json:"id,omitzero"`
+	Title        string      `json:"title,omitzero"`
+	Status       string      `json:"status,omitzero"`
+	Mode         string      `json:"mode,omitzero"`
+	Code         Code        `json:"code,omitzero"`
+	Entry        []ListEntry `json:"entry,omitzero"`
 }
 
 func (list ListResource) String() string {
@@ -119,9 +198,11 @@ func (list ListResource) String() string {
 }
 
 type Coding struct {
-	System  string
-	Code    string
-	Display string
+	System  string `json:"system,omitzero"`
+	Code    string `json:"code,omitzero"`
+	Display string `json:"display,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 func (c Coding) String() string {
@@ -129,8 +210,10 @@ func (c Coding) String() string {
 }
 
 type Code struct {
-	Coding []Coding
-	Text   string
+	Coding []Coding `json:"coding,omitzero"`
+	Text   string   `json:"text,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 func (cc Code) String() string {
@@ -145,7 +228,9 @@ func (cc Code) String() string {
 }
 
 type ListEntry struct {
-	Item ListEntryItem
+	Item ListEntryItem `json:"item,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 func (le ListEntry) String() string {
@@ -153,7 +238,9 @@ func (le ListEntry) String() string {
 }
 
 type ListEntryItem struct {
-	Reference string
+	Reference string `json:"reference,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 func (le ListEntryItem) IsTask() bool {
@@ -165,17 +252,21 @@ func (le ListEntryItem) ExtractTaskId() string {
 }
 
 type Task struct {
-	ResourceType string
-	Id           string
-	Identifier   []ResourceIdentifier
-	Status       string
-	Intent       string
-	Priority     string
-	Code         Code
-	For          Reference
-	AuthoredOn   string
-	LastModified string
-	Input        []TaskInput
+	ResourceType string               `json:"resourceType,omitzero"`
+	Id           string               `json:"id,omitzero"`
+	Meta         ResourceMeta         `json:"meta,omitzero"`
+	Extension    Extensions           `json:"extension,omitzero"`
+	Identifier   []ResourceIdentifier `json:"identifier,omitzero"`
+	Status       string               `json:"status,omitzero"`
+	Intent       string               `json:"intent,omitzero"`
+	Priority     string               `json:"priority,omitzero"`
+	Code         Code                 `json:"code,omitzero"`
+	For          Reference            `json:"for,omitzero"`
+	AuthoredOn   string               `json:"authoredOn,omitzero"`
+	LastModified string               `json:"lastModified,omitzero"`
+	Input        []TaskInput          `json:"input,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 func (t Task) ServiceRequestId() string {
@@ -183,29 +274,94 @@ func (t Task) ServiceRequestId() string {
 		return ""
 	}
 
-	ref := t.Input[0].ValueReference.Reference
-	return ref[strings.Index(ref, "/")+1:]
+	ref, err := t.Input[0].Value.AsReference()
+	if err != nil {
+		return ""
+	}
+
+	return ref.Reference[strings.Index(ref.Reference, "/")+1:]
 }
 
 type Reference struct {
-	Reference string
-	Display   string
+	Reference string `json:"reference,omitzero"`
+	Display   string `json:"display,omitzero"`
+
+	Extras Extras `json:"-"`
 }
 
 type TaskInput struct {
-	ValueReference Reference
+	Type  Code  `json:"type,omitzero"`
+	Value Value `json:"-"`
+
+	Extras Extras `json:"-"`
+}
+
+func (ti *TaskInput) UnmarshalJSON(data []byte) error {
+	type taskInput TaskInput
+
+	if err := unmarshalWithExtras(data, (*taskInput)(ti), &ti.Extras); err != nil {
+		return err
+	}
+	ti.Value = takeValue(&ti.Extras, "value")
+
+	return nil
+}
+
+func (ti TaskInput) MarshalJSON() ([]byte, error) {
+	type taskInput TaskInput
+	return marshalWithExtras(taskInput(ti), ti.Value.withMember("value", ti.Extras))
 }
 
 type ServiceRequest struct {
-	ResourceType       string
-	Id                 string
-	Identifier         []ResourceIdentifier
-	Status             string
-	Intent             string
-	Priority           string
-	Code               Code
-	Subject            Reference
-	Encounter          Reference
-	OccurrenceDateTime string
-	Performer          []Reference
+	ResourceType       string               `json:"resourceType,omitzero"`
+	Id                 string               `json:"id,omitzero"`
+	Meta               ResourceMeta         `json:"meta,omitzero"`
+	Extension          Extensions           `json:"extension,omitzero"`
+	Identifier         []ResourceIdentifier `json:"identifier,omitzero"`
+	Status             string               `json:"status,omitzero"`
+	Intent             string               `json:"intent,omitzero"`
+	Priority           string               `json:"priority,omitzero"`
+	Code               Code                 `json:"code,omitzero"`
+	Subject            Reference            `json:"subject,omitzero"`
+	Encounter          Reference            `json:"encounter,omitzero"`
+	OccurrenceDateTime string               `json:"occurrenceDateTime,omitzero"`
+	Performer          []Reference          `json:"performer,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type OperationOutcome struct {
+	ResourceType string                  `json:"resourceType,omitzero"`
+	Id           string                  `json:"id,omitzero"`
+	Issue        []OperationOutcomeIssue `json:"issue,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+func (oo OperationOutcome) String() string {
+	sb := strings.Builder{}
+	for _, issue := range oo.Issue {
+		sb.WriteString(p.Format("%s\n", issue))
+	}
+
+	return sb.String()
+}
+
+type OperationOutcomeIssue struct {
+	Severity    string   `json:"severity,omitzero"`
+	Code        string   `json:"code,omitzero"`
+	Details     Code     `json:"details,omitzero"`
+	Diagnostics string   `json:"diagnostics,omitzero"`
+	Expression  []string `json:"expression,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+func (issue OperationOutcomeIssue) String() string {
+	msg := p.Coalesce(issue.Diagnostics, issue.Details.Text)
+	if msg == "" && len(issue.Details.Coding) != 0 {
+		msg = issue.Details.Coding[0].Display
+	}
+
+	return p.Format("%s [%s]: %s", issue.Severity, issue.Code, msg)
 }
@@ -2,61 +2,160 @@ package agfa
 
 import (
 	"errors"
-	"net/http"
+	"io"
+	"slices"
+	"strings"
 
 	"golang.org/x/net/html"
 )
 
-func extractForm(resp *http.Response) (string, map[string]string, error) {
-	z := html.NewTokenizer(resp.Body)
+// htmlForm is a form on an HTML page, with its inputs in document order.
+type htmlForm struct {
+	Id     string
+	Action string
+	Inputs []formInput
+}
+
+type formInput struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+// hasPassword reports whether the form has a password field.
+func (f htmlForm) hasPassword() bool {
+	return slices.ContainsFunc(f.Inputs, func(in formInput) bool { return in.Type == "password" })
+}
+
+// input returns the first input whose name or type is one of names.
+func (f htmlForm) input(names ...string) (formInput, bool) {
+	for _, in := range f.Inputs {
+		if slices.Contains(names, in.Name) || slices.Contains(names, in.Type) {
+			return in, true
+		}
+	}
+
+	return formInput{}, false
+}
+
+// htmlPage is what parsePage gathers from an HTML page: its forms and the
+// error message an identity provider displays, if any.
+type htmlPage struct {
+	Forms []htmlForm
+	Error string
+}
+
+// errorMarkers are ids and classes of the elements Keycloak (and friends)
+// put error messages in, e.g. "Invalid username or password."
+var errorMarkers = []string{"input-error", "kc-feedback-text", "alert-error", "error-message", "kc-error-message"}
 
-	inForm := false
-	action := ""
-	inputs := make(map[string]string)
+func parsePage(r io.Reader) htmlPage {
+	z := html.NewTokenizer(r)
+
+	var (
+		page htmlPage
+		form *htmlForm
+
+		errTag   string
+		errDepth int
+		errText  strings.Builder
+	)
+
+	closeForm := func() {
+		if form != nil {
+			page.Forms = append(page.Forms, *form)
+			form = nil
+		}
+	}
 
 	for {
-		switch tt := z.Next(); tt {
+		tt := z.Next()
+		switch tt {
 		case html.ErrorToken:
-			if inForm {
-				return action, inputs, nil
+			closeForm()
+			return page
+		case html.TextToken:
+			if errDepth > 0 {
+				errText.Write(z.Text())
 			}
-
-			return "", nil, errors.New("form not found")
-		case html.StartTagToken:
+		case html.StartTagToken, html.SelfClosingTagToken:
 			t := z.Token()
-			if t.Data == "form" {
-				inForm = true
-				for _, attr := range t.Attr {
-					if attr.Key == "action" {
-						action = attr.Val
-					}
-				}
-			}
 
-			if inForm && t.Data == "input" {
-				var name, value string
-				for _, attr := range t.Attr {
-					switch attr.Key {
-					case "name":
-						name = attr.Val
-					case "value":
-						value = attr.Val
-					}
-				}
+			if errDepth > 0 && t.Data == errTag && tt == html.StartTagToken {
+				errDepth++
+			} else if errDepth == 0 && page.Error == "" && tt == html.StartTagToken && isErrorElement(t) {
+				errTag, errDepth = t.Data, 1
+			}
 
-				if name != "" {
-					inputs[name] = value
-				}
+			switch {
+			case t.Data == "form":
+				closeForm()
+				form = &htmlForm{Id: attr(t, "id"), Action: attr(t, "action")}
+			case form != nil && t.Data == "input":
+				form.Inputs = append(form.Inputs, formInput{
+					Name:  attr(t, "name"),
+					Type:  strings.ToLower(attr(t, "type")),
+					Value: attr(t, "value"),
+				})
 			}
 		case html.EndTagToken:
 			t := z.Token()
-			if inForm && t.Data == "form" {
-				if action == "" {
-					return "", nil, errors.New("form action not found")
+
+			if errDepth > 0 && t.Data == errTag {
+				if errDepth--; errDepth == 0 {
+					page.Error = strings.Join(strings.Fields(errText.String()), " ")
 				}
+			}
+
+			if t.Data == "form" {
+				closeForm()
+			}
+		}
+	}
+}
+
+func isErrorElement(t html.Token) bool {
+	if slices.Contains(errorMarkers, attr(t, "id")) {
+		return true
+	}
+
+	for _, class := range strings.Fields(attr(t, "class")) {
+		if slices.Contains(errorMarkers, class) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func attr(t html.Token, key string) string {
+	for _, a := range t.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
 
-				return action, inputs, nil
+	return ""
+}
+
+// loginForm picks the login form of a page: the form with the given id if
+// there is one, else the first form with a password field.
+func (page htmlPage) loginForm(id string) (htmlForm, error) {
+	for _, f := range page.Forms {
+		if id != "" && f.Id == id || id == "" && f.hasPassword() {
+			if f.Action == "" {
+				return htmlForm{}, errors.New("form action not found")
 			}
+			return f, nil
 		}
 	}
+
+	if page.Error != "" {
+		return htmlForm{}, &LoginError{Message: page.Error}
+	}
+	if id != "" {
+		return htmlForm{}, errors.New("form " + id + " not found")
+	}
+
+	return htmlForm{}, errors.New("login form not found")
 }
@@ -3,13 +3,21 @@ package agfa
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
-func TestExtractForm(t *testing.T) {
-	html := `
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		name  string
+		html  string
+		forms []htmlForm
+	}{
+		{
+			name: "hidden inputs",
+			html: `
 	<html>
 	    <body>
 	        <form action="/auth/realms/Agility/login" method="post">
@@ -20,51 +28,32 @@ func TestExtractForm(t *testing.T) {
 	            <input type="password" name="password">
 	        </form>
 	    </body>
-	</html>
-`
-
-	resp := makeResp(t, html)
-
-	action, inputs, err := extractForm(resp)
-	require.NoError(t, err)
-	require.Equal(t, "/auth/realms/Agility/login", action)
-	require.Equal(t, map[string]string{
-		"session_code": "abc123",
-		"execution":    "xyz789",
-		"client_id":    "myclient",
-		"username":     "",
-		"password":     "",
-	}, inputs)
-
-	html = `<html><body>No forms found.</body></html>`
-
-	resp = makeResp(t, html)
-
-	action, inputs, err = extractForm(resp)
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "form not found")
-	require.Empty(t, action)
-	require.Nil(t, inputs)
-
-	html = `
-	<html>
-	    <body>
-	        <form>
-		    <input type="hidden" name="x" value="1">
-		</form>
-	    </body>
-	</html>
-`
-
-	resp = makeResp(t, html)
-
-	action, inputs, err = extractForm(resp)
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "form action not found")
-	require.Empty(t, action)
-	require.Nil(t, inputs)
-
-	html = `
+	</html>`,
+			forms: []htmlForm{{
+				Action: "/auth/realms/Agility/login",
+				Inputs: []formInput{
+					{Name: "session_code", Type: "hidden", Value: "abc123"},
+					{Name: "execution", Type: "hidden", Value: "xyz789"},
+					{Name: "client_id", Type: "hidden", Value: "myclient"},
+					{Name: "username", Type: "text"},
+					{Name: "password", Type: "password"},
+				},
+			}},
+		},
+		{
+			name: "no forms",
+			html: `<html><body>No forms found.</body></html>`,
+		},
+		{
+			name: "no action",
+			html: `<html><body><form><input type="hidden" name="x" value="1"></form></body></html>`,
+			forms: []htmlForm{{
+				Inputs: []formInput{{Name: "x", Type: "hidden", Value: "1"}},
+			}},
+		},
+		{
+			name: "several forms",
+			html: `
 	<html>
 	    <body>
 	        <form action="/first">
@@ -74,69 +63,106 @@ func TestExtractForm(t *testing.T) {
 		    <input type="hidden" name="b" value"2">
 		</form>
 	    </body>
-	</html>
-`
-
-	resp = makeResp(t, html)
-
-	action, inputs, err = extractForm(resp)
-	require.NoError(t, err)
-	require.Equal(t, "/first", action)
-	require.Equal(t, map[string]string{"a": "1"}, inputs)
-
-	html = `
+	</html>`,
+			forms: []htmlForm{
+				{Action: "/first", Inputs: []formInput{{Name: "a", Type: "hidden", Value: "1"}}},
+				{Action: "/second", Inputs: []formInput{{Name: "b", Type: "hidden"}}},
+			},
+		},
+		{
+			name:  "empty form",
+			html:  `<html><body><form action="/login"></form></body></html>`,
+			forms: []htmlForm{{Action: "/login"}},
+		},
+		{
+			name: "unclosed form",
+			html: `
 	<html>
 	    <body>
-	        <form action="/login"></form>
+	        <form action="/ok">
+		    <input type="hidden" name="x" value="y">
+		<!-- Broken attributes; should recover -->
 	    </body>
-	</html>
-`
+	</html>`,
+			forms: []htmlForm{{Action: "/ok", Inputs: []formInput{{Name: "x", Type: "hidden", Value: "y"}}}},
+		},
+		{
+			name: "broken markup",
+			html: `
+	<html>
+	    <body>
+	        <div <span <p> missing closing tags
+	    </body>
+	</html>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := parsePage(strings.NewReader(tt.html))
+			require.Equal(t, tt.forms, page.Forms)
+			require.Empty(t, page.Error)
+		})
+	}
+}
 
-	resp = makeResp(t, html)
+func makeResp(t *testing.T, html string) *http.Response {
+	t.Helper()
 
-	action, inputs, err = extractForm(resp)
-	require.NoError(t, err)
-	require.Equal(t, "/login", action)
-	require.Empty(t, inputs)
+	rec := httptest.NewRecorder()
+	rec.WriteString(html)
+	return rec.Result()
+}
 
-	html = `
+func TestParsePage_LoginForm(t *testing.T) {
+	html := `
 	<html>
 	    <body>
-	        <form action="/ok">
-		    <input type="hidden" name="x" value="y">
-		<!-- Broken attributes; should recover -->
+	        <form id="kc-locale" action="/locale"><input type="hidden" name="kc_locale" value="en"></form>
+	        <form id="kc-form-login" action="login-actions/authenticate?session_code=abc&amp;execution=xyz">
+	            <input type="hidden" name="credentialId" value="">
+	            <input type="text" name="username">
+	            <input type="password" name="password">
+	            <input type="submit" name="login" value="Log In">
+	        </form>
 	    </body>
 	</html>
 `
 
-	resp = makeResp(t, html)
+	page := parsePage(strings.NewReader(html))
+	require.Len(t, page.Forms, 2)
+	require.Empty(t, page.Error)
+
+	form, err := page.loginForm("")
+	require.NoError(t, err)
+	require.Equal(t, "kc-form-login", form.Id)
+	require.Equal(t, "login-actions/authenticate?session_code=abc&execution=xyz", form.Action)
 
-	action, inputs, err = extractForm(resp)
+	form, err = page.loginForm("kc-locale")
 	require.NoError(t, err)
-	require.Equal(t, "/ok", action)
-	require.Equal(t, map[string]string{"x": "y"}, inputs)
+	require.Equal(t, "/locale", form.Action)
+
+	_, err = page.loginForm("nope")
+	require.ErrorContains(t, err, "form nope not found")
+}
 
-	html = `
+func TestParsePage_Error(t *testing.T) {
+	html := `
 	<html>
 	    <body>
-	        <div <span <p> missing closing tags
+	        <div class="alert alert-error">
+	            <span class="pficon pficon-error-circle-o"></span>
+	            <span class="kc-feedback-text">Account is disabled, contact your administrator.</span>
+	        </div>
 	    </body>
 	</html>
 `
 
-	resp = makeResp(t, html)
+	page := parsePage(strings.NewReader(html))
+	require.Equal(t, "Account is disabled, contact your administrator.", page.Error)
 
-	action, inputs, err = extractForm(resp)
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "form not found")
-	require.Empty(t, action)
-	require.Nil(t, inputs)
-}
-
-func makeResp(t *testing.T, html string) *http.Response {
-	t.Helper()
-
-	rec := httptest.NewRecorder()
-	rec.WriteString(html)
-	return rec.Result()
+	_, err := page.loginForm("")
+	var loginErr *LoginError
+	require.ErrorAs(t, err, &loginErr)
+	require.Equal(t, "Account is disabled, contact your administrator.", loginErr.Message)
 }
@@ -0,0 +1,341 @@
+package agfa
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exchange describes one HTTP request sent by a Client, including those of
+// the login flow and token requests, and its response.
+type Exchange struct {
+	Request *http.Request
+	Method  string
+	Url     string
+	// Attempt counts the tries of a retried request, starting at 1.
+	Attempt int
+	Start   time.Time
+
+	// Set once the exchange is done.
+	Response      *http.Response
+	StatusCode    int
+	Duration      time.Duration
+	BytesSent     int64
+	BytesReceived int64
+	Err           error
+
+	// Redactor is the client's, for hooks to scrub what they log.
+	Redactor *Redactor
+}
+
+// Hook observes a Client's HTTP exchanges. Before is called as a request
+// is sent. After is called once its response body has been read and
+// closed, or right away if the request failed. Hooks may be called
+// concurrently and must not modify the request or response.
+type Hook interface {
+	Before(ex *Exchange)
+	After(ex *Exchange)
+}
+
+// WithHook registers hooks to observe every HTTP exchange.
+func WithHook(hooks ...Hook) func(*Client) {
+	return func(client *Client) {
+		client.hooks = append(client.hooks, hooks...)
+	}
+}
+
+type attemptKey struct{}
+
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+func attemptOf(ctx context.Context) int {
+	if n, ok := ctx.Value(attemptKey{}).(int); ok {
+		return n
+	}
+
+	return 1
+}
+
+// hookMiddleware reports every exchange going through it to hooks.
+func hookMiddleware(hooks []Hook, redactor *Redactor) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ex := &Exchange{
+				Request:   req,
+				Method:    req.Method,
+				Url:       req.URL.String(),
+				Attempt:   attemptOf(req.Context()),
+				Start:     time.Now(),
+				BytesSent: max(req.ContentLength, 0),
+				Redactor:  redactor,
+			}
+			for _, h := range hooks {
+				h.Before(ex)
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				ex.Err = err
+				ex.Duration = time.Since(ex.Start)
+				for _, h := range hooks {
+					h.After(ex)
+				}
+				return nil, err
+			}
+
+			ex.Response = resp
+			ex.StatusCode = resp.StatusCode
+			resp.Body = &hookBody{ReadCloser: resp.Body, ex: ex, hooks: hooks}
+
+			return resp, nil
+		})
+	}
+}
+
+// hookBody counts the bytes read from a response body and finishes the
+// exchange when it is closed.
+type hookBody struct {
+	io.ReadCloser
+	ex    *Exchange
+	hooks []Hook
+	once  sync.Once
+}
+
+func (b *hookBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.ex.BytesReceived += int64(n)
+	return n, err
+}
+
+func (b *hookBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		b.ex.Duration = time.Since(b.ex.Start)
+		for _, h := range b.hooks {
+			h.After(b.ex)
+		}
+	})
+
+	return err
+}
+
+// SlogHook logs every exchange with structured attributes: at Level, or at
+// warning level if the request failed or got a 5xx response.
+type SlogHook struct {
+	Logger *slog.Logger
+	Level  slog.Level
+}
+
+// NewSlogHook logs exchanges to logger at info level.
+func NewSlogHook(logger *slog.Logger) *SlogHook {
+	return &SlogHook{Logger: logger, Level: slog.LevelInfo}
+}
+
+func (h *SlogHook) Before(*Exchange) {}
+
+func (h *SlogHook) After(ex *Exchange) {
+	attrs := []slog.Attr{
+		slog.String("method", ex.Method),
+		slog.String("url", ex.Redactor.URL(ex.Url)),
+		slog.Int("status", ex.StatusCode),
+		slog.Duration("duration", ex.Duration),
+		slog.Int64("bytes_sent", ex.BytesSent),
+		slog.Int64("bytes_received", ex.BytesReceived),
+		slog.Int("attempt", ex.Attempt),
+	}
+
+	level := h.Level
+	if ex.Err != nil {
+		attrs = append(attrs, slog.String("error", ex.Redactor.String(ex.Err.Error())))
+		level = slog.LevelWarn
+	} else if ex.StatusCode >= 500 {
+		level = slog.LevelWarn
+	}
+
+	ctx := context.Background()
+	if ex.Request != nil {
+		ctx = ex.Request.Context()
+	}
+	h.Logger.LogAttrs(ctx, level, "http exchange", attrs...)
+}
+
+// DefaultBuckets are the upper bounds, in seconds, of the request duration
+// histogram of Metrics.
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Metrics is a Hook counting exchanges Prometheus-style: requests and bytes
+// by method and status code, plus a duration histogram by method. Serve
+// WritePrometheus on a /metrics endpoint to have them scraped.
+type Metrics struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	requests  map[metricKey]uint64
+	received  map[metricKey]uint64
+	sent      map[metricKey]uint64
+	retries   map[string]uint64
+	durations map[string]*histogram
+}
+
+type metricKey struct {
+	method string
+	code   string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics creates a Metrics hook with the given histogram buckets, or
+// DefaultBuckets if none are given.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	return &Metrics{
+		buckets:   slices.Sorted(slices.Values(buckets)),
+		requests:  map[metricKey]uint64{},
+		received:  map[metricKey]uint64{},
+		sent:      map[metricKey]uint64{},
+		retries:   map[string]uint64{},
+		durations: map[string]*histogram{},
+	}
+}
+
+func (m *Metrics) Before(*Exchange) {}
+
+func (m *Metrics) After(ex *Exchange) {
+	key := metricKey{method: ex.Method, code: fmt.Sprint(ex.StatusCode)}
+	if ex.Err != nil {
+		key.code = "error"
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[key]++
+	m.sent[key] += uint64(ex.BytesSent)
+	m.received[key] += uint64(ex.BytesReceived)
+	if ex.Attempt > 1 {
+		m.retries[ex.Method]++
+	}
+
+	h := m.durations[ex.Method]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.durations[ex.Method] = h
+	}
+	secs := ex.Duration.Seconds()
+	for i, le := range m.buckets {
+		if secs <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition
+// format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	writeCounter := func(name, help string, values map[metricKey]uint64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, k := range slices.SortedFunc(maps.Keys(values), compareKeys) {
+			fmt.Fprintf(&b, "%s{method=%q,code=%q} %d\n", name, k.method, k.code, values[k])
+		}
+	}
+
+	writeCounter("agfa_http_requests_total", "HTTP requests sent, by method and status code.", m.requests)
+	writeCounter("agfa_http_request_bytes_total", "Bytes of request bodies sent.", m.sent)
+	writeCounter("agfa_http_response_bytes_total", "Bytes of response bodies received.", m.received)
+
+	b.WriteString("# HELP agfa_http_retries_total Retried HTTP requests, by method.\n# TYPE agfa_http_retries_total counter\n")
+	for _, method := range slices.Sorted(maps.Keys(m.retries)) {
+		fmt.Fprintf(&b, "agfa_http_retries_total{method=%q} %d\n", method, m.retries[method])
+	}
+
+	b.WriteString("# HELP agfa_http_request_duration_seconds HTTP request durations, by method.\n# TYPE agfa_http_request_duration_seconds histogram\n")
+	for _, method := range slices.Sorted(maps.Keys(m.durations)) {
+		h := m.durations[method]
+		for i, le := range m.buckets {
+			fmt.Fprintf(&b, "agfa_http_request_duration_seconds_bucket{method=%q,le=\"%g\"} %d\n", method, le, h.counts[i])
+		}
+		fmt.Fprintf(&b, "agfa_http_request_duration_seconds_bucket{method=%q,le=\"+Inf\"} %d\n", method, h.count)
+		fmt.Fprintf(&b, "agfa_http_request_duration_seconds_sum{method=%q} %g\n", method, h.sum)
+		fmt.Fprintf(&b, "agfa_http_request_duration_seconds_count{method=%q} %d\n", method, h.count)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP serves the metrics, so a Metrics can be mounted as /metrics.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WritePrometheus(w)
+}
+
+func compareKeys(a, b metricKey) int {
+	return strings.Compare(a.method+" "+a.code, b.method+" "+b.code)
+}
+
+// TraceHook dumps each exchange to W in the style of curl -v: the request
+// line and headers, then the status line and headers of the response.
+// Everything is scrubbed with the client's Redactor.
+type TraceHook struct {
+	W io.Writer
+
+	mu sync.Mutex
+}
+
+func (h *TraceHook) Before(*Exchange) {}
+
+func (h *TraceHook) After(ex *Exchange) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "> %s %s", ex.Method, ex.Redactor.URL(ex.Url))
+	if ex.Attempt > 1 {
+		fmt.Fprintf(&b, " (attempt %d)", ex.Attempt)
+	}
+	b.WriteString("\n")
+	if ex.Request != nil {
+		writeHeaders(&b, "> ", ex.Redactor.Header(ex.Request.Header))
+	}
+
+	if ex.Err != nil {
+		fmt.Fprintf(&b, "! %s (%s)\n", ex.Redactor.String(ex.Err.Error()), ex.Duration.Round(time.Millisecond))
+	} else {
+		fmt.Fprintf(&b, "< %s (%s, %d bytes)\n", ex.Response.Status, ex.Duration.Round(time.Millisecond), ex.BytesReceived)
+		writeHeaders(&b, "< ", ex.Redactor.Header(ex.Response.Header))
+	}
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	io.WriteString(h.W, b.String())
+}
+
+func writeHeaders(b *strings.Builder, prefix string, header http.Header) {
+	for _, k := range slices.Sorted(maps.Keys(header)) {
+		for _, v := range header[k] {
+			fmt.Fprintf(b, "%s%s: %s\n", prefix, k, v)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package agfa
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingHook struct {
+	mu     sync.Mutex
+	before int
+	after  []Exchange
+}
+
+func (h *recordingHook) Before(*Exchange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.before++
+}
+
+func (h *recordingHook) After(ex *Exchange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.after = append(h.after, *ex)
+}
+
+// newFlakyServer fails the first request with 503, then serves {"foo": "bar"}.
+func newFlakyServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"foo": "bar"}`)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestHook(t *testing.T) {
+	ts := newFlakyServer(t)
+
+	hook := &recordingHook{}
+	c := NewClient(ts.URL, WithHook(hook), WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, RetryableStatus: []int{503}}))
+
+	var got objStruct
+	require.NoError(t, c.Get("Task/1", nil, &got))
+
+	require.Equal(t, 2, hook.before)
+	require.Len(t, hook.after, 2)
+	require.Equal(t, http.StatusServiceUnavailable, hook.after[0].StatusCode)
+	require.Equal(t, 1, hook.after[0].Attempt)
+	require.Equal(t, http.StatusOK, hook.after[1].StatusCode)
+	require.Equal(t, 2, hook.after[1].Attempt)
+	require.Equal(t, int64(len(`{"foo": "bar"}`)), hook.after[1].BytesReceived)
+	require.Equal(t, http.MethodGet, hook.after[1].Method)
+	require.True(t, strings.HasSuffix(hook.after[1].Url, "/Task/1"))
+}
+
+func TestHook_TransportError(t *testing.T) {
+	hook := &recordingHook{}
+	c := NewClient("http://127.0.0.1:1", WithHook(hook), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+	var got objStruct
+	require.Error(t, c.Get("Task/1", nil, &got))
+	require.Len(t, hook.after, 1)
+	require.Error(t, hook.after[0].Err)
+}
+
+func TestMetrics(t *testing.T) {
+	ts := newFlakyServer(t)
+
+	m := NewMetrics(0.5, 1)
+	c := NewClient(ts.URL, WithHook(m), WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, RetryableStatus: []int{503}}))
+
+	var got objStruct
+	require.NoError(t, c.Get("Task/1", nil, &got))
+
+	var b bytes.Buffer
+	require.NoError(t, m.WritePrometheus(&b))
+	out := b.String()
+	require.Contains(t, out, `agfa_http_requests_total{method="GET",code="200"} 1`)
+	require.Contains(t, out, `agfa_http_requests_total{method="GET",code="503"} 1`)
+	require.Contains(t, out, `agfa_http_response_bytes_total{method="GET",code="200"} 14`)
+	require.Contains(t, out, `agfa_http_retries_total{method="GET"} 1`)
+	require.Contains(t, out, `agfa_http_request_duration_seconds_bucket{method="GET",le="0.5"} 2`)
+	require.Contains(t, out, `agfa_http_request_duration_seconds_count{method="GET"} 2`)
+}
+
+func TestSlogHook(t *testing.T) {
+	ts := newFlakyServer(t)
+
+	var b bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&b, nil))
+	c := NewClient(ts.URL, WithHook(NewSlogHook(logger)), WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, RetryableStatus: []int{503}}))
+
+	var got objStruct
+	require.NoError(t, c.Get("Task/1", nil, &got))
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var rec map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &rec))
+	require.Equal(t, "WARN", rec["level"])
+	require.EqualValues(t, 503, rec["status"])
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &rec))
+	require.Equal(t, "INFO", rec["level"])
+	require.EqualValues(t, 2, rec["attempt"])
+}
+
+func TestTraceHook(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s3cret"})
+		io.WriteString(w, `{"foo": "bar"}`)
+	}))
+	defer ts.Close()
+
+	var b bytes.Buffer
+	c := NewClient(ts.URL, WithHook(&TraceHook{W: &b}), WithAuthenticator(BearerToken("t0ken")))
+
+	var got objStruct
+	require.NoError(t, c.Get("Task/1", nil, &got))
+
+	out := b.String()
+	require.Contains(t, out, "> GET "+ts.URL+"/Task/1")
+	require.Contains(t, out, "> Authorization: [REDACTED]")
+	require.Contains(t, out, "< 200 OK")
+	require.Contains(t, out, "< Set-Cookie: [REDACTED]")
+	require.NotContains(t, out, "t0ken")
+	require.NotContains(t, out, "s3cret")
+}
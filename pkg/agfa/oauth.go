@@ -0,0 +1,410 @@
+package agfa
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryDelta is how long before its expiry a token is refreshed.
+const tokenExpiryDelta = 30 * time.Second
+
+// Token is an OAuth2 access token.
+type Token struct {
+	AccessToken  string    `json:"accessToken"`
+	TokenType    string    `json:"tokenType"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	Expiry       time.Time `json:"expiry,omitzero"`
+}
+
+// Valid reports whether the token is set and not about to expire.
+func (t Token) Valid() bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(t.Expiry))
+}
+
+// Grant obtains access tokens from an OAuth2 token endpoint. See
+// ClientCredentials, PasswordGrant and SMARTBackend.
+type Grant interface {
+	// form returns the token request parameters for a new token.
+	form(tokenUrl string) (url.Values, error)
+	endpoint() string
+}
+
+// ClientCredentials is the OAuth2 client credentials grant.
+type ClientCredentials struct {
+	TokenUrl     string
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
+}
+
+func (g ClientCredentials) endpoint() string { return g.TokenUrl }
+
+func (g ClientCredentials) form(string) (url.Values, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {g.ClientId},
+		"client_secret": {g.ClientSecret},
+	}
+	setScope(form, g.Scopes)
+
+	return form, nil
+}
+
+// PasswordGrant is the OAuth2 resource owner password grant, as offered by
+// the Keycloak realm behind the Agfa login form. Tokens are renewed with
+// the refresh token while the server hands one out.
+type PasswordGrant struct {
+	TokenUrl     string
+	ClientId     string
+	ClientSecret string
+	Username     string
+	Password     string
+	Scopes       []string
+}
+
+func (g PasswordGrant) endpoint() string { return g.TokenUrl }
+
+func (g PasswordGrant) form(string) (url.Values, error) {
+	form := url.Values{
+		"grant_type": {"password"},
+		"client_id":  {g.ClientId},
+		"username":   {g.Username},
+		"password":   {g.Password},
+	}
+	if g.ClientSecret != "" {
+		form.Set("client_secret", g.ClientSecret)
+	}
+	setScope(form, g.Scopes)
+
+	return form, nil
+}
+
+// SMARTBackend is the SMART backend services flow: a client credentials
+// grant authenticated with a JWT signed by the client's private key
+// (private_key_jwt). Key must be an RSA key (signed with RS384) or an
+// ECDSA P-384 key (signed with ES384).
+type SMARTBackend struct {
+	TokenUrl string
+	ClientId string
+	KeyId    string
+	Key      crypto.Signer
+	Scopes   []string
+}
+
+func (g SMARTBackend) endpoint() string { return g.TokenUrl }
+
+func (g SMARTBackend) form(tokenUrl string) (url.Values, error) {
+	assertion, err := g.assertion(tokenUrl)
+	if err != nil {
+		return nil, fmt.Errorf("client assertion: %v", err)
+	}
+
+	form := url.Values{
+		"grant_type":            {"client_credentials"},
+		"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+		"client_assertion":      {assertion},
+	}
+	setScope(form, g.Scopes)
+
+	return form, nil
+}
+
+func (g SMARTBackend) assertion(tokenUrl string) (string, error) {
+	var alg string
+	switch key := g.Key.(type) {
+	case *rsa.PrivateKey:
+		alg = "RS384"
+	case *ecdsa.PrivateKey:
+		// SMART only allows ES384, which is defined for P-384 alone
+		if key.Curve != elliptic.P384() {
+			return "", fmt.Errorf("ECDSA key must be on curve P-384, got %s", key.Curve.Params().Name)
+		}
+		alg = "ES384"
+	default:
+		return "", fmt.Errorf("unsupported key type %T", g.Key)
+	}
+
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if g.KeyId != "" {
+		header["kid"] = g.KeyId
+	}
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss": g.ClientId,
+		"sub": g.ClientId,
+		"aud": tokenUrl,
+		"exp": now.Add(5 * time.Minute).Unix(),
+		"iat": now.Unix(),
+		"jti": newUUID(),
+	}
+
+	signingInput := b64(mustJSON(header)) + "." + b64(mustJSON(claims))
+	digest := sha512.Sum384([]byte(signingInput))
+
+	var sig []byte
+	switch key := g.Key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA384, digest[:]); err != nil {
+			return "", err
+		}
+	case *ecdsa.PrivateKey:
+		// JWS wants the raw r||s pair rather than ASN.1
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			return "", err
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		sig = append(pad(r, size), pad(s, size)...)
+	}
+
+	return signingInput + "." + b64(sig), nil
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS#8, PKCS#1 or SEC 1 private key,
+// e.g. for SMARTBackend.Key.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("unsupported private key format")
+}
+
+func setScope(form url.Values, scopes []string) {
+	if len(scopes) != 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func pad(n *big.Int, size int) []byte {
+	return n.FillBytes(make([]byte, size))
+}
+
+// WithOAuth2 authenticates every request with a bearer token obtained
+// through grant. It is shorthand for WithAuthenticator(&OAuth2Authenticator{Grant: grant}).
+func WithOAuth2(grant Grant) func(*Client) {
+	return WithAuthenticator(&OAuth2Authenticator{Grant: grant})
+}
+
+// OAuth2Authenticator authenticates with bearer tokens obtained through an
+// OAuth2 grant, fetching a new token shortly before the current one
+// expires. If the grant has no TokenUrl, the client's TokenUrl is used, or
+// else the one discovered with DiscoverTokenUrl.
+type OAuth2Authenticator struct {
+	Grant Grant
+
+	mu       sync.Mutex
+	tokenUrl string
+	token    Token
+}
+
+func (a *OAuth2Authenticator) Authenticate(ctx context.Context, client *Client) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.token = Token{}
+	_, err := a.get(ctx, client)
+	return err
+}
+
+// Refresh drops the current token, e.g. one revoked before its expiry, and
+// fetches a new one, using the refresh token if there is one.
+func (a *OAuth2Authenticator) Refresh(ctx context.Context, client *Client) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.token.AccessToken = ""
+	_, err := a.get(ctx, client)
+	return err
+}
+
+func (a *OAuth2Authenticator) Apply(client *Client, req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	token, err := a.get(req.Context(), client)
+	if err != nil {
+		return fmt.Errorf("oauth2: %w", err)
+	}
+	req.Header.Set("Authorization", token.TokenType+" "+token.AccessToken)
+
+	return nil
+}
+
+// get returns a valid token, fetching a new one if needed. Callers must
+// hold a.mu.
+func (a *OAuth2Authenticator) get(ctx context.Context, client *Client) (Token, error) {
+	if a.token.Valid() {
+		return a.token, nil
+	}
+
+	if a.tokenUrl == "" {
+		a.tokenUrl = a.Grant.endpoint()
+		if a.tokenUrl == "" {
+			a.tokenUrl = client.TokenUrl
+		}
+		if a.tokenUrl == "" {
+			u, err := client.DiscoverTokenUrl(ctx)
+			if err != nil {
+				return Token{}, fmt.Errorf("token url: %v", err)
+			}
+			a.tokenUrl = u
+		}
+	}
+
+	form, err := a.Grant.form(a.tokenUrl)
+	if err != nil {
+		return Token{}, err
+	}
+	for _, key := range []string{"password", "client_secret", "client_assertion"} {
+		client.redactor.AddSecret(form.Get(key))
+	}
+
+	// prefer the refresh token, but fall back to the grant if it is refused
+	if a.token.RefreshToken != "" {
+		refresh := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {a.token.RefreshToken},
+			"client_id":     form["client_id"],
+		}
+		if form.Has("client_secret") {
+			refresh.Set("client_secret", form.Get("client_secret"))
+		}
+
+		if token, err := requestToken(ctx, client.hc, a.tokenUrl, refresh); err == nil {
+			a.setToken(client, token)
+			return token, nil
+		}
+	}
+
+	token, err := requestToken(ctx, client.hc, a.tokenUrl, form)
+	if err != nil {
+		return Token{}, err
+	}
+	a.setToken(client, token)
+
+	return token, nil
+}
+
+func (a *OAuth2Authenticator) setToken(client *Client, token Token) {
+	client.redactor.AddSecret(token.AccessToken)
+	client.redactor.AddSecret(token.RefreshToken)
+	a.token = token
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func requestToken(ctx context.Context, hc *http.Client, tokenUrl string, form url.Values) (Token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, fmt.Errorf("token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Token{}, fmt.Errorf("token response: %v", err)
+	}
+
+	var tr tokenResponse
+	if err = json.Unmarshal(body, &tr); err != nil {
+		return Token{}, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, body)
+	}
+
+	if resp.StatusCode != http.StatusOK || tr.AccessToken == "" {
+		return Token{}, fmt.Errorf("token endpoint returned %d: %s %s", resp.StatusCode, tr.Error, tr.ErrorDescription)
+	}
+
+	token := Token{
+		AccessToken:  tr.AccessToken,
+		TokenType:    tr.TokenType,
+		RefreshToken: tr.RefreshToken,
+	}
+	if token.TokenType == "" || strings.EqualFold(token.TokenType, "bearer") {
+		token.TokenType = "Bearer"
+	}
+	if tr.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+// DiscoverTokenUrl finds the token endpoint of the Keycloak realm the FHIR
+// server redirects unauthenticated requests to, by swapping the /auth path
+// of its OpenID Connect authorization url for /token.
+func (client *Client) DiscoverTokenUrl(ctx context.Context) (string, error) {
+	authUrl, err := client.getRedirectUrl(ctx, client.Base())
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(authUrl)
+	if err != nil {
+		return "", fmt.Errorf("invalid login redirect %q: %v", authUrl, err)
+	}
+
+	path, ok := strings.CutSuffix(u.Path, "/protocol/openid-connect/auth")
+	if !ok {
+		return "", fmt.Errorf("login redirect %q is not an OpenID Connect authorization url", authUrl)
+	}
+
+	u.Path = path + "/protocol/openid-connect/token"
+	u.RawQuery = ""
+
+	return u.String(), nil
+}
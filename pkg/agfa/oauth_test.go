@@ -0,0 +1,189 @@
+package agfa
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTokenServer serves a token endpoint at /token handing out numbered
+// tokens, and a FHIR endpoint that only accepts the latest one.
+func newTokenServer(t *testing.T, check func(r *http.Request)) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var issued atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			require.NoError(t, r.ParseForm())
+			check(r)
+			n := issued.Add(1)
+			fmt.Fprintf(w, `{"access_token":"tok-%d","token_type":"bearer","expires_in":300,"refresh_token":"ref-%d"}`, n, n)
+			return
+		}
+
+		if r.Header.Get("Authorization") != fmt.Sprintf("Bearer tok-%d", issued.Load()) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"resourceType":"Patient","id":"p1"}`)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &issued
+}
+
+func TestOAuth2_ClientCredentials(t *testing.T) {
+	ts, issued := newTokenServer(t, func(r *http.Request) {
+		require.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		require.Equal(t, "svc", r.PostForm.Get("client_id"))
+		require.Equal(t, "s3cret", r.PostForm.Get("client_secret"))
+		require.Equal(t, "system/*.read", r.PostForm.Get("scope"))
+	})
+
+	c := NewClient(ts.URL, WithOAuth2(ClientCredentials{
+		TokenUrl:     ts.URL + "/token",
+		ClientId:     "svc",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"system/*.read"},
+	}))
+
+	for range 2 {
+		patient, err := Read[Patient](context.Background(), c, "p1")
+		require.NoError(t, err)
+		require.Equal(t, "p1", patient.Id)
+	}
+	require.EqualValues(t, 1, issued.Load(), "token should be cached")
+}
+
+func TestOAuth2_RefreshOnUnauthorized(t *testing.T) {
+	var grants []string
+	ts, issued := newTokenServer(t, func(r *http.Request) {
+		grants = append(grants, r.PostForm.Get("grant_type"))
+	})
+
+	c := NewClient(ts.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}), WithOAuth2(PasswordGrant{
+		ClientId: "agfa",
+		Username: "user",
+		Password: "pass",
+	}))
+	c.TokenUrl = ts.URL + "/token"
+
+	_, err := Read[Patient](context.Background(), c, "p1")
+	require.NoError(t, err)
+
+	// revoke the token behind the client's back
+	issued.Add(1)
+
+	_, err = Read[Patient](context.Background(), c, "p1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"password", "refresh_token"}, grants)
+}
+
+func TestOAuth2_TokenError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"invalid_client","error_description":"bad secret"}`)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, WithOAuth2(ClientCredentials{TokenUrl: ts.URL + "/token"}))
+
+	_, err := Read[Patient](context.Background(), c, "p1")
+	require.ErrorContains(t, err, "invalid_client bad secret")
+}
+
+func TestSMARTBackend_Assertion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	for _, key := range []crypto.Signer{rsaKey, ecKey} {
+		g := SMARTBackend{ClientId: "svc", KeyId: "k1", Key: key}
+		form, err := g.form("https://idp/token")
+		require.NoError(t, err)
+		require.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", form.Get("client_assertion_type"))
+
+		parts := strings.Split(form.Get("client_assertion"), ".")
+		require.Len(t, parts, 3)
+
+		var header, claims map[string]any
+		require.NoError(t, json.Unmarshal(unb64(t, parts[0]), &header))
+		require.NoError(t, json.Unmarshal(unb64(t, parts[1]), &claims))
+		require.Equal(t, "k1", header["kid"])
+		require.Equal(t, "svc", claims["iss"])
+		require.Equal(t, "https://idp/token", claims["aud"])
+
+		digest := sha512.Sum384([]byte(parts[0] + "." + parts[1]))
+		sig := unb64(t, parts[2])
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			require.Equal(t, "RS384", header["alg"])
+			require.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA384, digest[:], sig))
+		case *ecdsa.PrivateKey:
+			require.Equal(t, "ES384", header["alg"])
+			require.Len(t, sig, 96)
+			r, s := new(big.Int).SetBytes(sig[:48]), new(big.Int).SetBytes(sig[48:])
+			require.True(t, ecdsa.Verify(&key.PublicKey, digest[:], r, s))
+		}
+	}
+}
+
+func TestSMARTBackend_WrongCurve(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	g := SMARTBackend{ClientId: "svc", Key: key}
+	_, err = g.form("https://idp/token")
+	require.ErrorContains(t, err, "must be on curve P-384, got P-256")
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	signer, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	require.True(t, key.Equal(signer))
+
+	_, err = ParsePrivateKey([]byte("not a key"))
+	require.Error(t, err)
+}
+
+func TestDiscoverTokenUrl(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "https://idp.example/auth/realms/Agility/protocol/openid-connect/auth?client_id=x")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer ts.Close()
+
+	u, err := NewClient(ts.URL).DiscoverTokenUrl(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "https://idp.example/auth/realms/Agility/protocol/openid-connect/token", u)
+}
+
+func unb64(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
@@ -0,0 +1,75 @@
+package agfa
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrOTPRequired is returned when the identity provider asks for a
+// one-time code but SessionParams.OTP is not set.
+var ErrOTPRequired = errors.New("agfa: login requires a one-time code")
+
+// otpFields are the names Keycloak has given the OTP input over the years.
+var otpFields = []string{"otp", "totp"}
+
+// otpChallenge is the OTP form an identity provider shows after the
+// password step. It is passed around as an error by getAuthRedirect.
+type otpChallenge struct {
+	form    htmlForm
+	pageUrl *url.URL
+	// message is shown along with the form if a code was rejected
+	message string
+}
+
+func (c *otpChallenge) Error() string {
+	return "identity provider asked for a one-time code"
+}
+
+// otpForm returns the page's form asking for a one-time code, if any.
+func (page htmlPage) otpForm() (htmlForm, bool) {
+	for _, f := range page.Forms {
+		if _, ok := f.input(otpFields...); ok && !f.hasPassword() && f.Action != "" {
+			return f, true
+		}
+	}
+
+	return htmlForm{}, false
+}
+
+// TOTP returns a SessionParams.OTP function generating RFC 6238 codes (6
+// digits, 30 second steps, HMAC-SHA1, as authenticator apps do) from the
+// base32 secret shown when the authenticator was enrolled.
+func TOTP(secret string) func(context.Context) (string, error) {
+	return func(context.Context) (string, error) {
+		return totpCode(secret, time.Now())
+	}
+}
+
+func totpCode(secret string, t time.Time) (string, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %v", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/30))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0xf
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", code%1_000_000), nil
+}
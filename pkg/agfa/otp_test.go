@@ -0,0 +1,114 @@
+package agfa
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTotpCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to 6 digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for ts, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+	} {
+		code, err := totpCode(secret, time.Unix(ts, 0))
+		require.NoError(t, err)
+		require.Equal(t, want, code)
+	}
+
+	_, err := totpCode("not base32!", time.Now())
+	require.Error(t, err)
+}
+
+const otpFormHTML = `
+	<html>
+	    <body>
+	        <form id="kc-otp-login-form" action="otp-submit?execution=e2" method="post">
+	            <input type="hidden" name="selectedCredentialId" value="c1">
+	            <input id="otp" name="otp" type="text" autocomplete="off">
+	            <input type="submit" name="login" value="Sign In">
+	        </form>
+	    </body>
+	</html>`
+
+// newOTPAgfa is newFakeAgfa with an OTP step after the password form,
+// accepting only the code 123456.
+func newOTPAgfa(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, buildLoginHTML("/submit"))
+		case "/submit":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, otpFormHTML)
+		case "/otp-submit":
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "c1", r.PostForm.Get("selectedCredentialId"))
+			if r.PostForm.Get("otp") != "123456" {
+				w.Header().Set("Content-Type", "text/html")
+				io.WriteString(w, `<span id="input-error">Invalid authenticator code.</span>`+otpFormHTML)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "ok", Path: "/"})
+			http.Redirect(w, r, "/List", http.StatusFound)
+		default:
+			if c, err := r.Cookie("sid"); err != nil || c.Value != "ok" {
+				http.Redirect(w, r, "/login", http.StatusFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/fhir+json")
+			io.WriteString(w, `{"foo": "bar"}`)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestSession_OTP(t *testing.T) {
+	ts := newOTPAgfa(t)
+
+	prompts := 0
+	c, err := NewClient(ts.URL).Session(SessionParams{
+		Username: "user",
+		Password: "pass",
+		OTP: func(context.Context) (string, error) {
+			prompts++
+			return "123456", nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, prompts)
+
+	var got objStruct
+	require.NoError(t, c.Get("Task/1", nil, &got))
+	require.Equal(t, "bar", got.Foo)
+}
+
+func TestSession_OTPFailures(t *testing.T) {
+	ts := newOTPAgfa(t)
+
+	_, err := NewClient(ts.URL).Session(SessionParams{Username: "user", Password: "pass"})
+	require.ErrorIs(t, err, ErrOTPRequired)
+
+	_, err = NewClient(ts.URL).Session(SessionParams{
+		Username: "user",
+		Password: "pass",
+		OTP:      func(context.Context) (string, error) { return "000000", nil },
+	})
+	var loginErr *LoginError
+	require.ErrorAs(t, err, &loginErr)
+	require.Equal(t, "Invalid authenticator code.", loginErr.Message)
+}
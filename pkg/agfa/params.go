@@ -0,0 +1,129 @@
+package agfa
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Prefix compares ordered values (numbers, dates, quantities) in a search.
+type Prefix string
+
+const (
+	PrefixEq Prefix = "eq"
+	PrefixNe Prefix = "ne"
+	PrefixGt Prefix = "gt"
+	PrefixLt Prefix = "lt"
+	PrefixGe Prefix = "ge"
+	PrefixLe Prefix = "le"
+	PrefixSa Prefix = "sa"
+	PrefixEb Prefix = "eb"
+	PrefixAp Prefix = "ap"
+)
+
+// SearchParams builds the query of a FHIR search. Unlike a map, it keeps
+// repeated parameters, e.g. both bounds of a date range:
+//
+//	params := agfa.NewSearchParams().
+//		Prefix("authored-on", agfa.PrefixGe, "2025-01-01").
+//		Prefix("authored-on", agfa.PrefixLt, "2025-02-01").
+//		Sort("-authored-on").
+//		Count(100)
+type SearchParams struct {
+	values url.Values
+}
+
+func NewSearchParams() *SearchParams {
+	return &SearchParams{values: url.Values{}}
+}
+
+// Add appends value to name, keeping any values already set.
+func (sp *SearchParams) Add(name string, values ...string) *SearchParams {
+	sp.values[name] = append(sp.values[name], values...)
+	return sp
+}
+
+// Set replaces all values of name. Several values are OR'd together by
+// the server.
+func (sp *SearchParams) Set(name string, values ...string) *SearchParams {
+	sp.values.Set(name, strings.Join(values, ","))
+	return sp
+}
+
+// Modifier adds name:modifier=value, e.g. Modifier("family", "exact", "Doe").
+func (sp *SearchParams) Modifier(name, modifier, value string) *SearchParams {
+	return sp.Add(name+":"+modifier, value)
+}
+
+func (sp *SearchParams) Exact(name, value string) *SearchParams {
+	return sp.Modifier(name, "exact", value)
+}
+
+func (sp *SearchParams) Missing(name string, missing bool) *SearchParams {
+	return sp.Modifier(name, "missing", strconv.FormatBool(missing))
+}
+
+// Prefix adds name=<prefix><value>, e.g. Prefix("date", PrefixGe, "2025").
+func (sp *SearchParams) Prefix(name string, prefix Prefix, value string) *SearchParams {
+	return sp.Add(name, string(prefix)+value)
+}
+
+// Chain adds a chained parameter, e.g. Chain("subject", "Patient", "name",
+// "Doe") for subject:Patient.name=Doe. targetType may be empty when the
+// reference can only point to one resource type.
+func (sp *SearchParams) Chain(name, targetType, targetParam, value string) *SearchParams {
+	if targetType != "" {
+		name += ":" + targetType
+	}
+
+	return sp.Add(name+"."+targetParam, value)
+}
+
+func (sp *SearchParams) Id(ids ...string) *SearchParams {
+	return sp.Set("_id", ids...)
+}
+
+// Sort orders results by the given params; prefix one with "-" to sort it
+// descending.
+func (sp *SearchParams) Sort(params ...string) *SearchParams {
+	return sp.Set("_sort", params...)
+}
+
+func (sp *SearchParams) Count(n int) *SearchParams {
+	sp.values.Set("_count", strconv.Itoa(n))
+	return sp
+}
+
+func (sp *SearchParams) Elements(elements ...string) *SearchParams {
+	return sp.Set("_elements", elements...)
+}
+
+// Include adds _include=sourceType:param, e.g. Include("Task", "input").
+func (sp *SearchParams) Include(sourceType, param string) *SearchParams {
+	return sp.Add("_include", sourceType+":"+param)
+}
+
+// IncludeIterate is like Include, but also applies to included resources.
+func (sp *SearchParams) IncludeIterate(sourceType, param string) *SearchParams {
+	return sp.Add("_include:iterate", sourceType+":"+param)
+}
+
+// RevInclude adds _revinclude=sourceType:param, pulling in the resources
+// which reference the matches.
+func (sp *SearchParams) RevInclude(sourceType, param string) *SearchParams {
+	return sp.Add("_revinclude", sourceType+":"+param)
+}
+
+// Values returns a copy of the built query.
+func (sp *SearchParams) Values() url.Values {
+	values := make(url.Values, len(sp.values))
+	for k, v := range sp.values {
+		values[k] = append([]string(nil), v...)
+	}
+
+	return values
+}
+
+func (sp *SearchParams) Encode() string {
+	return sp.values.Encode()
+}
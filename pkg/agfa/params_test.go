@@ -0,0 +1,62 @@
+package agfa
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchParams(t *testing.T) {
+	params := NewSearchParams().
+		Prefix("authored-on", PrefixGe, "2025-01-01").
+		Prefix("authored-on", PrefixLt, "2025-02-01").
+		Exact("code:text", "CT HEAD").
+		Missing("owner", true).
+		Chain("subject", "Patient", "identifier", "MRN|123").
+		Id("1", "2").
+		Sort("-authored-on", "priority").
+		Count(50).
+		Elements("id", "status").
+		Include("Task", "input").
+		IncludeIterate("ServiceRequest", "subject").
+		RevInclude("Communication", "about")
+
+	values := params.Values()
+	require.Equal(t, []string{"ge2025-01-01", "lt2025-02-01"}, values["authored-on"])
+	require.Equal(t, "CT HEAD", values.Get("code:text:exact"))
+	require.Equal(t, "true", values.Get("owner:missing"))
+	require.Equal(t, "MRN|123", values.Get("subject:Patient.identifier"))
+	require.Equal(t, "1,2", values.Get("_id"))
+	require.Equal(t, "-authored-on,priority", values.Get("_sort"))
+	require.Equal(t, "50", values.Get("_count"))
+	require.Equal(t, "id,status", values.Get("_elements"))
+	require.Equal(t, "Task:input", values.Get("_include"))
+	require.Equal(t, "ServiceRequest:subject", values.Get("_include:iterate"))
+	require.Equal(t, "Communication:about", values.Get("_revinclude"))
+
+	// Values hands out a copy
+	values.Del("_id")
+	require.Equal(t, "1,2", params.Values().Get("_id"))
+
+	params.Count(10)
+	require.Equal(t, []string{"10"}, params.Values()["_count"])
+}
+
+func TestClientGetQuery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, []string{"ge2025", "le2026"}, r.URL.Query()["date"])
+		w.Write([]byte(`{"foo": "bar"}`))
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+	query := url.Values{"date": {"ge2025", "le2026"}}
+
+	var got objStruct
+	require.NoError(t, client.GetQuery(context.Background(), "Task", query, &got))
+	require.Equal(t, "bar", got.Foo)
+}
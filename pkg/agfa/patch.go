@@ -0,0 +1,142 @@
+package agfa
+
+import (
+	"encoding/json"
+)
+
+// Patch is a patch document accepted by Client.Patch.
+type Patch interface {
+	ContentType() string
+}
+
+// JSONPatch is an RFC 6902 JSON Patch document.
+type JSONPatch []JSONPatchOp
+
+type JSONPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitzero"`
+	Value any    `json:"value,omitempty"`
+}
+
+func (JSONPatch) ContentType() string {
+	return "application/json-patch+json"
+}
+
+// FHIRPatch builds a FHIR Patch document, a Parameters resource listing one
+// "operation" per change, with paths given as FHIRPath expressions:
+//
+//	patch := agfa.NewFHIRPatch().Replace("Task.status", statusValue)
+type FHIRPatch struct {
+	Parameters
+}
+
+func NewFHIRPatch() *FHIRPatch {
+	return &FHIRPatch{Parameters{ResourceType: "Parameters"}}
+}
+
+func (FHIRPatch) ContentType() string {
+	return "application/fhir+json"
+}
+
+func (fp *FHIRPatch) operation(typ, path string, parts ...ParametersParameter) *FHIRPatch {
+	op := ParametersParameter{
+		Name: "operation",
+		Part: []ParametersParameter{
+			{Name: "type", Value: Value{Type: "Code", Raw: mustJSON(typ)}},
+			{Name: "path", Value: Value{Type: "String", Raw: mustJSON(path)}},
+		},
+	}
+	op.Part = append(op.Part, parts...)
+	fp.Parameter = append(fp.Parameter, op)
+
+	return fp
+}
+
+// Add adds value as the element name of the element at path.
+func (fp *FHIRPatch) Add(path, name string, value Value) *FHIRPatch {
+	return fp.operation("add", path,
+		ParametersParameter{Name: "name", Value: Value{Type: "String", Raw: mustJSON(name)}},
+		ParametersParameter{Name: "value", Value: value},
+	)
+}
+
+// Insert inserts value into the list at path, at the given index.
+func (fp *FHIRPatch) Insert(path string, index int, value Value) *FHIRPatch {
+	return fp.operation("insert", path,
+		ParametersParameter{Name: "index", Value: Value{Type: "Integer", Raw: mustJSON(index)}},
+		ParametersParameter{Name: "value", Value: value},
+	)
+}
+
+func (fp *FHIRPatch) Delete(path string) *FHIRPatch {
+	return fp.operation("delete", path)
+}
+
+func (fp *FHIRPatch) Replace(path string, value Value) *FHIRPatch {
+	return fp.operation("replace", path, ParametersParameter{Name: "value", Value: value})
+}
+
+// Move moves the item of the list at path from one index to another.
+func (fp *FHIRPatch) Move(path string, source, destination int) *FHIRPatch {
+	return fp.operation("move", path,
+		ParametersParameter{Name: "source", Value: Value{Type: "Integer", Raw: mustJSON(source)}},
+		ParametersParameter{Name: "destination", Value: Value{Type: "Integer", Raw: mustJSON(destination)}},
+	)
+}
+
+type Parameters struct {
+	ResourceType string                `json:"resourceType,omitzero"`
+	Id           string                `json:"id,omitzero"`
+	Parameter    []ParametersParameter `json:"parameter,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+func (Parameters) TypeName() string { return "Parameters" }
+
+func (ps *Parameters) UnmarshalJSON(data []byte) error {
+	type parameters Parameters
+	return unmarshalWithExtras(data, (*parameters)(ps), &ps.Extras)
+}
+
+func (ps Parameters) MarshalJSON() ([]byte, error) {
+	type parameters Parameters
+	return marshalWithExtras(parameters(ps), ps.Extras)
+}
+
+type ParametersParameter struct {
+	Name     string                `json:"name,omitzero"`
+	Value    Value                 `json:"-"`
+	Resource json.RawMessage       `json:"resource,omitzero"`
+	Part     []ParametersParameter `json:"part,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+func (pp *ParametersParameter) UnmarshalJSON(data []byte) error {
+	type parametersParameter ParametersParameter
+
+	if err := unmarshalWithExtras(data, (*parametersParameter)(pp), &pp.Extras); err != nil {
+		return err
+	}
+	pp.Value = takeValue(&pp.Extras, "value")
+
+	return nil
+}
+
+func (pp ParametersParameter) MarshalJSON() ([]byte, error) {
+	type parametersParameter ParametersParameter
+	return marshalWithExtras(parametersParameter(pp), pp.Value.withMember("value", pp.Extras))
+}
+
+// mustJSON marshals values which cannot fail to encode, like strings and
+// ints.
+func mustJSON(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
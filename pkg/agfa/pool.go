@@ -0,0 +1,117 @@
+package agfa
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultBatchSize is how many Tasks ResolveWorklist asks for per search.
+const DefaultBatchSize = 50
+
+// DefaultConcurrency is the number of workers FetchAll uses when given none.
+const DefaultConcurrency = 8
+
+// FetchAll calls fetch for every key on a pool of at most concurrency
+// workers. Results and errors are returned in the order of keys. Once ctx is
+// cancelled no new keys are picked up, and those left over report ctx.Err().
+func FetchAll[K, V any](ctx context.Context, concurrency int, keys []K, fetch func(context.Context, K) (V, error)) ([]V, []error) {
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	results := make([]V, len(keys))
+	errs := make([]error, len(keys))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(concurrency, len(keys)) {
+		wg.Go(func() {
+			for i := range jobs {
+				results[i], errs[i] = fetch(ctx, keys[i])
+			}
+		})
+	}
+
+feed:
+	for i := range keys {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			for j := i; j < len(keys); j++ {
+				errs[j] = ctx.Err()
+			}
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, errs
+}
+
+// WithConcurrency sets how many requests the client's own fan-outs, like
+// ResolveWorklist, keep in flight at once.
+func WithConcurrency(n int) func(*Client) {
+	return func(client *Client) {
+		client.concurrency = n
+	}
+}
+
+// WithBatchSize sets how many Tasks ResolveWorklist looks up per search
+// request. A size of 1 or less turns batching off, resolving every entry with
+// its own GETs.
+func WithBatchSize(n int) func(*Client) {
+	return func(client *Client) {
+		client.batchSize = n
+	}
+}
+
+// WithRateLimit caps the client at rps FHIR requests per second, shared
+// across every goroutine using it. A non-positive rps means no limit.
+func WithRateLimit(rps float64) func(*Client) {
+	return func(client *Client) {
+		client.limiter = nil
+		if rps > 0 {
+			client.limiter = &rateLimiter{interval: time.Duration(float64(time.Second) / rps)}
+		}
+	}
+}
+
+// rateLimiter hands out evenly spaced time slots.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the caller's slot comes up or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	slot := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	d := time.Until(slot)
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,73 @@
+package agfa
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchAll(t *testing.T) {
+	keys := make([]int, 50)
+	for i := range keys {
+		keys[i] = i
+	}
+
+	var inFlight, peak atomic.Int32
+	results, errs := FetchAll(context.Background(), 4, keys, func(ctx context.Context, k int) (string, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+
+		if k == 7 {
+			return "", errors.New("boom")
+		}
+		return strconv.Itoa(k), nil
+	})
+
+	require.LessOrEqual(t, peak.Load(), int32(4))
+	require.Len(t, results, 50)
+	require.Equal(t, "42", results[42])
+	require.EqualError(t, errs[7], "boom")
+	require.NoError(t, errs[8])
+}
+
+func TestFetchAll_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	keys := []int{1, 2, 3, 4, 5}
+	_, errs := FetchAll(ctx, 1, keys, func(ctx context.Context, k int) (int, error) {
+		if k == 2 {
+			cancel()
+		}
+		return k, nil
+	})
+
+	require.NoError(t, errs[0])
+	require.ErrorIs(t, errs[4], context.Canceled)
+}
+
+func TestRateLimiter(t *testing.T) {
+	client := &Client{}
+	WithRateLimit(100)(client)
+
+	start := time.Now()
+	for range 5 {
+		require.NoError(t, client.limiter.wait(context.Background()))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	WithRateLimit(0)(client)
+	require.Nil(t, client.limiter)
+	require.NoError(t, client.limiter.wait(context.Background()))
+}
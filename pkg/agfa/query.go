@@ -1,13 +1,12 @@
 package agfa
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-
-	"github.com/s-hammon/p"
 )
 
 func (client *Client) reqUrl(endpoint ...string) *url.URL {
@@ -25,16 +24,32 @@ func (client *Client) reqUrl(endpoint ...string) *url.URL {
 }
 
 func (client *Client) Get(endpoint string, params map[string]string, obj any) error {
+	return client.GetContext(context.Background(), endpoint, params, obj)
+}
+
+// GetContext is like Get, but the request is bound to ctx.
+func (client *Client) GetContext(ctx context.Context, endpoint string, params map[string]string, obj any) error {
+	query := url.Values{}
+	for k, v := range params {
+		query.Set(k, v)
+	}
+
+	return client.GetQuery(ctx, endpoint, query, obj)
+}
+
+// GetQuery is like GetContext, but takes the query as url.Values, so that
+// parameters can be repeated. See SearchParams.
+func (client *Client) GetQuery(ctx context.Context, endpoint string, query url.Values, obj any) error {
 	u := client.reqUrl(endpoint)
-	if len(params) != 0 {
+	if len(query) != 0 {
 		q := u.Query()
-		for k, v := range params {
-			q.Set(k, v)
+		for k, v := range query {
+			q[k] = v
 		}
 		u.RawQuery = q.Encode()
 	}
 
-	resp, err := client.get(u)
+	resp, err := client.get(ctx, u)
 	if err != nil {
 		return err
 	}
@@ -43,10 +58,11 @@ func (client *Client) Get(endpoint string, params map[string]string, obj any) er
 	return decode(resp.Body, obj)
 }
 
-func (client *Client) get(u *url.URL) (*http.Response, error) {
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+// newRequest builds a FHIR request carrying the client's auth headers.
+func (client *Client) newRequest(ctx context.Context, method string, u *url.URL, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
 	if err != nil {
-		return nil, fmt.Errorf("http.NewRequest: %v", err)
+		return nil, fmt.Errorf("http.NewRequestWithContext: %v", err)
 	}
 
 	for k, v := range client.authHeaders {
@@ -54,30 +70,116 @@ func (client *Client) get(u *url.URL) (*http.Response, error) {
 	}
 	req.Header.Set("Accept", "application/fhir+json")
 
-	resp, err := client.hc.Do(req)
+	if client.auth != nil {
+		if err = client.auth.Apply(client, req); err != nil {
+			return nil, client.redactor.Error(err)
+		}
+	}
+
+	return req, nil
+}
+
+func (client *Client) get(ctx context.Context, u *url.URL) (*http.Response, error) {
+	return client.getWithHeader(ctx, u, nil)
+}
+
+// getWithHeader is like get, with extra request headers. A 304 response to
+// a conditional request is reported as ErrNotModified.
+func (client *Client) getWithHeader(ctx context.Context, u *url.URL, header http.Header) (*http.Response, error) {
+	req, err := client.newRequest(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.doRetry(req)
 	if err != nil {
-		return nil, fmt.Errorf("http GET: %v", err)
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return nil, ErrNotModified
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("FHIR GET failed: %d %s", resp.StatusCode, body)
+		return nil, client.newError(resp, body)
 	}
 
 	return resp, nil
 }
 
-func (client *Client) FetchListById(listId string) (List, error) {
-	params := map[string]string{
-		"_format": "json",
+// do sends req, refreshing the client's credentials and retrying once if
+// the response shows that the session has expired.
+func (client *Client) do(req *http.Request) (*http.Response, error) {
+	gen := client.generation()
+
+	if err := client.limiter.wait(req.Context()); err != nil {
+		return nil, client.redactor.Error(fmt.Errorf("http %s: %w", req.Method, err))
+	}
+
+	resp, err := client.hc.Do(req)
+	if err != nil {
+		return nil, client.redactor.Error(fmt.Errorf("http %s: %w", req.Method, err))
+	}
+
+	if !client.sessionExpired(resp) {
+		return resp, nil
+	}
+
+	if !client.canRelogin() {
+		if resp.StatusCode == http.StatusUnauthorized {
+			return resp, nil
+		}
+		resp.Body.Close()
+		return nil, ErrSessionExpired
+	}
+	resp.Body.Close()
+
+	if err = client.relogin(req.Context(), gen); err != nil {
+		return nil, client.redactor.Error(fmt.Errorf("%w: re-login failed: %v", ErrSessionExpired, err))
+	}
+
+	if err = client.limiter.wait(req.Context()); err != nil {
+		return nil, client.redactor.Error(fmt.Errorf("http %s: %w", req.Method, err))
 	}
 
-	var list List
-	err := client.Get(p.Format("List/%s", listId), params, &list)
-	return list, err
+	retry := cloneRequest(req)
+	if err = client.auth.Apply(client, retry); err != nil {
+		return nil, err
+	}
+
+	resp, err = client.hc.Do(retry)
+	if err != nil {
+		return nil, client.redactor.Error(fmt.Errorf("http %s: %w", req.Method, err))
+	}
+
+	if client.sessionExpired(resp) && resp.StatusCode != http.StatusUnauthorized {
+		resp.Body.Close()
+		return nil, ErrSessionExpired
+	}
+
+	return resp, nil
+}
+
+func (client *Client) FetchListById(listId string) (List, error) {
+	return client.FetchListByIdContext(context.Background(), listId)
+}
+
+func (client *Client) FetchListByIdContext(ctx context.Context, listId string) (List, error) {
+	return Read[List](ctx, client, listId)
 }
 
 func (client *Client) FetchBundleById(bundleId string) (Bundle, error) {
+	return client.FetchBundleByIdContext(context.Background(), bundleId)
+}
+
+func (client *Client) FetchBundleByIdContext(ctx context.Context, bundleId string) (Bundle, error) {
 	params := map[string]string{
 		"_id":     bundleId,
 		"_format": "json",
@@ -85,27 +187,40 @@ func (client *Client) FetchBundleById(bundleId string) (Bundle, error) {
 		// "_include:iterate": "List:entry.item",
 	}
 	var bundle Bundle
-	err := client.Get("List", params, &bundle)
+	err := client.GetContext(ctx, "List", params, &bundle)
 	return bundle, err
 }
 
 func (client *Client) FetchTaskById(taskId string) (Task, error) {
-	params := map[string]string{
-		"_format": "json",
-	}
-	var task Task
-	err := client.Get(p.Format("Task/%s", taskId), params, &task)
-	return task, err
+	return client.FetchTaskByIdContext(context.Background(), taskId)
+}
+
+func (client *Client) FetchTaskByIdContext(ctx context.Context, taskId string) (Task, error) {
+	return Read[Task](ctx, client, taskId)
 }
 
 func (client *Client) FetchServiceRequestById(reqId string) (ServiceRequest, error) {
-	params := map[string]string{
-		"_id":     reqId,
-		"_format": "json",
+	return client.FetchServiceRequestByIdContext(context.Background(), reqId)
+}
+
+func (client *Client) FetchServiceRequestByIdContext(ctx context.Context, reqId string) (ServiceRequest, error) {
+	return Read[ServiceRequest](ctx, client, reqId)
+}
+
+// cloneRequest copies req for another attempt, rewinding its body. The
+// cookie jar stamps its cookies onto the request it is given, so those are
+// dropped to let the jar supply fresh ones.
+func cloneRequest(req *http.Request) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Header.Del("Cookie")
+
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			clone.Body = body
+		}
 	}
-	var serviceRequest ServiceRequest
-	err := client.Get(p.Format("ServiceRequest/%s", reqId), params, &serviceRequest)
-	return serviceRequest, err
+
+	return clone
 }
 
 func decode(r io.Reader, obj any) error {
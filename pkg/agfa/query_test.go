@@ -1,6 +1,7 @@
 package agfa
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -97,3 +98,20 @@ func TestClientGet_NetworkError(t *testing.T) {
 	err := client.Get("server/down", nil, &got)
 	require.Error(t, err)
 }
+
+func TestClientGetContext_Cancelled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var got objStruct
+	err := client.GetContext(ctx, "slow/request", nil, &got)
+	require.Error(t, err)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,384 @@
+package agfa
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Redacted replaces every scrubbed value.
+const Redacted = "[REDACTED]"
+
+// DefaultPHIFields are the JSON members scrubbed by a Redactor with PHI
+// set: the display of references to people, such as Task.for or
+// ServiceRequest.subject, identifiers (MRNs, accession numbers), names and
+// patient demographics. The displays of codings are left alone.
+//
+// Each entry is a path of member names, which matches members whose path
+// within their resource ends with it, e.g. "subject.display". A path
+// starting with a resource type, e.g. "Patient.name", only matches from the
+// root of resources of that type.
+var DefaultPHIFields = []string{
+	"for.display", "subject.display", "patient.display", "performer.display",
+	"requester.display", "owner.display", "individual.display",
+	"referrer.display", "resultsInterpreter.display", "authorReference.display",
+	"identifier",
+	"Patient.name", "Practitioner.name", "RelatedPerson.name",
+	"birthDate", "telecom", "address",
+}
+
+// sensitiveHeaders carry credentials and are always scrubbed.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// sensitiveParams matches query and form parameters carrying credentials
+// or login state, which are always scrubbed.
+var sensitiveParams = regexp.MustCompile(`(^|[?&;\s"'])(password|client_secret|client_assertion|access_token|refresh_token|session_code|otp|totp)=[^&\s"'#]*`)
+
+// authCodeParam matches an OAuth authorization code. Since code is also a
+// common FHIR search parameter, it is only scrubbed from text that looks
+// like part of a login: an identity provider url, an authorization
+// response (which comes with a state) or a token request.
+var (
+	authCodeParam = regexp.MustCompile(`(^|[?&;\s"'])(code)=[^&\s"'#]*`)
+	loginMarkers  = regexp.MustCompile(`openid-connect|login-actions|[?&]state=|grant_type=`)
+)
+
+// Redactor scrubs credentials, and optionally PHI, from text the client
+// logs or puts in errors. Credentials are the headers and url parameters
+// that carry them, plus every secret the client's Authenticator uses,
+// which it registers with AddSecret. The zero value scrubs credentials
+// only, a nil Redactor scrubs nothing but credential headers and
+// parameters, and both are safe for concurrent use.
+type Redactor struct {
+	// PHI turns on scrubbing of the members in PHIFields from JSON.
+	PHI bool
+	// PHIFields overrides DefaultPHIFields, with entries of the same form.
+	PHIFields []string
+
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// WithRedactor makes the client scrub errors and hook output with r. By
+// default clients scrub credentials only.
+func WithRedactor(r *Redactor) func(*Client) {
+	return func(client *Client) {
+		client.redactor = r
+	}
+}
+
+// AddSecret makes r scrub every occurrence of secret, e.g. a password.
+func (r *Redactor) AddSecret(secret string) {
+	if r == nil || len(secret) < 4 {
+		// scrubbing short strings would mangle everything else
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !slices.Contains(r.secrets, secret) {
+		r.secrets = append(r.secrets, secret)
+	}
+}
+
+// String scrubs secrets and sensitive url parameters from s, and PHI if s
+// is a JSON document.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+
+	if r.PHI {
+		if trimmed := strings.TrimSpace(s); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			if b, err := r.json([]byte(trimmed)); err == nil {
+				return string(b)
+			}
+		}
+	}
+
+	return r.text(s)
+}
+
+// text scrubs secrets and sensitive parameters from free text.
+func (r *Redactor) text(s string) string {
+	if r != nil {
+		r.mu.RLock()
+		for _, secret := range r.secrets {
+			s = strings.ReplaceAll(s, secret, Redacted)
+			// secrets also show up form or query encoded
+			if esc := url.QueryEscape(secret); esc != secret {
+				s = strings.ReplaceAll(s, esc, Redacted)
+			}
+		}
+		r.mu.RUnlock()
+	}
+
+	s = sensitiveParams.ReplaceAllString(s, "${1}${2}="+Redacted)
+	if loginMarkers.MatchString(s) {
+		s = authCodeParam.ReplaceAllString(s, "${1}${2}="+Redacted)
+	}
+
+	return s
+}
+
+// URL scrubs a url of its user info password and sensitive parameters,
+// and with PHI set, of search parameters named after PHI fields, such as
+// identifier=MRN123 or subject.name:exact=Doe.
+func (r *Redactor) URL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return r.text(u)
+	}
+
+	if parsed.User != nil {
+		if _, ok := parsed.User.Password(); ok {
+			u = strings.Replace(u, parsed.User.String()+"@", url.PathEscape(parsed.User.Username())+":"+Redacted+"@", 1)
+		}
+	}
+
+	if r != nil && r.PHI && parsed.RawQuery != "" {
+		pairs := strings.Split(parsed.RawQuery, "&")
+		for i, pair := range pairs {
+			k, _, _ := strings.Cut(pair, "=")
+			name, err := url.QueryUnescape(k)
+			if err != nil {
+				continue
+			}
+			name, _, _ = strings.Cut(name, ":")
+			if j := strings.LastIndex(name, "."); j >= 0 {
+				name = name[j+1:]
+			}
+			if r.isPHIParam(name) {
+				pairs[i] = k + "=" + Redacted
+			}
+		}
+		u = strings.Replace(u, "?"+parsed.RawQuery, "?"+strings.Join(pairs, "&"), 1)
+	}
+
+	return r.text(u)
+}
+
+// Header returns a copy of h with sensitive headers scrubbed.
+func (r *Redactor) Header(h http.Header) http.Header {
+	out := h.Clone()
+	for k, vs := range out {
+		for i := range vs {
+			if slices.Contains(sensitiveHeaders, k) {
+				vs[i] = Redacted
+			} else {
+				vs[i] = r.text(vs[i])
+			}
+		}
+	}
+
+	return out
+}
+
+// JSON scrubs a JSON document, keeping its layout as far as possible. The
+// string values of PHI members, including those nested in them, are
+// replaced if PHI is set. Documents that don't parse are scrubbed as text.
+func (r *Redactor) JSON(b []byte) []byte {
+	if r == nil {
+		return b
+	}
+
+	out, err := r.json(b)
+	if err != nil {
+		return []byte(r.text(string(b)))
+	}
+
+	return out
+}
+
+func (r *Redactor) json(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.jsonValue(b, &buf, phiPath{}); err != nil {
+		return nil, err
+	}
+
+	// keep indentation if the document had any
+	if bytes.ContainsRune(b, '\n') {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, buf.Bytes(), "", "  "); err == nil {
+			return indented.Bytes(), nil
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// phiPath is where jsonValue is in a document: the type of the innermost
+// resource, the member names leading there from its root, and whether an
+// enclosing member is PHI.
+type phiPath struct {
+	resource string
+	members  []string
+	phi      bool
+}
+
+type jsonMember struct {
+	key   string
+	value json.RawMessage
+}
+
+func (r *Redactor) jsonValue(b []byte, buf *bytes.Buffer, at phiPath) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			// members are read ahead, since the resource type decides
+			// which of them are PHI
+			var members []jsonMember
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				var value json.RawMessage
+				if err = dec.Decode(&value); err != nil {
+					return err
+				}
+				members = append(members, jsonMember{key.(string), value})
+			}
+
+			for _, m := range members {
+				var typ string
+				if m.key == "resourceType" && json.Unmarshal(m.value, &typ) == nil {
+					at = phiPath{resource: typ, phi: at.phi}
+				}
+			}
+
+			buf.WriteByte('{')
+			for i, m := range members {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				writeJSONString(buf, m.key)
+				buf.WriteByte(':')
+
+				child := phiPath{resource: at.resource, members: append(slices.Clip(at.members), m.key)}
+				child.phi = at.phi || r.isPHI(child)
+				if err = r.jsonValue(m.value, buf, child); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte('}')
+		} else {
+			buf.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				var value json.RawMessage
+				if err = dec.Decode(&value); err != nil {
+					return err
+				}
+				if err = r.jsonValue(value, buf, at); err != nil {
+					return err
+				}
+			}
+			buf.WriteByte(']')
+		}
+		_, err = dec.Token() // closing delimiter
+		return err
+	case string:
+		if at.phi {
+			t = Redacted
+		} else {
+			t = r.text(t)
+		}
+		writeJSONString(buf, t)
+	case json.Number:
+		buf.WriteString(t.String())
+	case bool:
+		buf.WriteString(map[bool]string{true: "true", false: "false"}[t])
+	case nil:
+		buf.WriteString("null")
+	}
+
+	return nil
+}
+
+func (r *Redactor) phiFields() []string {
+	if r.PHIFields != nil {
+		return r.PHIFields
+	}
+
+	return DefaultPHIFields
+}
+
+// isPHI reports whether the member at the given path is one of the PHI
+// fields.
+func (r *Redactor) isPHI(at phiPath) bool {
+	if r == nil || !r.PHI {
+		return false
+	}
+
+	for _, field := range r.phiFields() {
+		segs := strings.Split(field, ".")
+		if first := segs[0]; first != "" && unicode.IsUpper(rune(first[0])) {
+			if first == at.resource && slices.Equal(segs[1:], at.members) {
+				return true
+			}
+		} else if len(segs) <= len(at.members) && slices.Equal(segs, at.members[len(at.members)-len(segs):]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isPHIParam is isPHI for search parameters, which are named after the
+// last member of a PHI field, all lower case, and split names into family
+// and given.
+func (r *Redactor) isPHIParam(name string) bool {
+	if name == "family" || name == "given" {
+		name = "name"
+	}
+
+	return slices.ContainsFunc(r.phiFields(), func(f string) bool {
+		return strings.EqualFold(f[strings.LastIndex(f, ".")+1:], name)
+	})
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
+
+// Error returns err with its message scrubbed. errors.Is and errors.As
+// still see the original error.
+func (r *Redactor) Error(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+
+	msg := err.Error()
+	if scrubbed := r.String(msg); scrubbed != msg {
+		return &redactedError{err: err, msg: scrubbed}
+	}
+
+	return err
+}
+
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
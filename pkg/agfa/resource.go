@@ -0,0 +1,86 @@
+package agfa
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"reflect"
+
+	"github.com/s-hammon/p"
+)
+
+// Resource is implemented by every FHIR resource type in this package.
+// TypeName must work on the zero value, since Read and Search call it to
+// find out which endpoint to query.
+type Resource interface {
+	TypeName() string
+}
+
+// typeName returns the resource type of T, which may also be a pointer to
+// a resource, e.g. *Patient.
+func typeName[T Resource]() string {
+	if rt := reflect.TypeFor[T](); rt.Kind() == reflect.Pointer {
+		if res, ok := reflect.New(rt.Elem()).Interface().(Resource); ok {
+			return res.TypeName()
+		}
+	}
+
+	var res T
+	return res.TypeName()
+}
+
+func (List) TypeName() string             { return "List" }
+func (Task) TypeName() string             { return "Task" }
+func (ServiceRequest) TypeName() string   { return "ServiceRequest" }
+func (OperationOutcome) TypeName() string { return "OperationOutcome" }
+func (Patient) TypeName() string          { return "Patient" }
+func (Encounter) TypeName() string        { return "Encounter" }
+func (ImagingStudy) TypeName() string     { return "ImagingStudy" }
+func (Practitioner) TypeName() string     { return "Practitioner" }
+func (Organization) TypeName() string     { return "Organization" }
+func (DiagnosticReport) TypeName() string { return "DiagnosticReport" }
+func (Observation) TypeName() string      { return "Observation" }
+
+// Read fetches the resource of type T with the given id, e.g.
+//
+//	patient, err := agfa.Read[agfa.Patient](ctx, client, "123")
+func Read[T Resource](ctx context.Context, client *Client, id string) (T, error) {
+	params := map[string]string{
+		"_format": "json",
+	}
+
+	var res T
+	err := client.GetContext(ctx, p.Format("%s/%s", typeName[T](), id), params, &res)
+	return res, err
+}
+
+// Search runs a FHIR search for resources of type T across every page.
+// Entries of other types, such as those pulled in by _include, are skipped
+// but still count towards WithMaxResults.
+func Search[T Resource](ctx context.Context, client *Client, params url.Values, opts ...func(*SearchOptions)) iter.Seq2[T, error] {
+	var zero T
+	resourceType := typeName[T]()
+
+	return func(yield func(T, error) bool) {
+		for entry, err := range client.Search(ctx, resourceType, params, opts...) {
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			if entry.ResourceType() != resourceType {
+				continue
+			}
+
+			var res T
+			if err = entry.Decode(&res); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			if !yield(res, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package agfa
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/Patient/123", r.URL.Path)
+		require.Equal(t, "json", r.URL.Query().Get("_format"))
+		io.WriteString(w, `{
+			"resourceType": "Patient",
+			"id": "123",
+			"name": [{"family": "Doe", "given": ["Jane", "Q"]}],
+			"birthDate": "1970-01-01"
+		}`)
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+
+	patient, err := Read[Patient](context.Background(), client, "123")
+	require.NoError(t, err)
+	require.Equal(t, "123", patient.Id)
+	require.Equal(t, "1970-01-01", patient.BirthDate)
+	require.Equal(t, "Jane Q Doe", patient.Name[0].String())
+}
+
+func TestFetchServiceRequestById_PathOnly(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/ServiceRequest/sr1", r.URL.Path)
+		require.False(t, r.URL.Query().Has("_id"))
+		io.WriteString(w, `{"resourceType": "ServiceRequest", "id": "sr1"}`)
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+
+	svcReq, err := client.FetchServiceRequestById("sr1")
+	require.NoError(t, err)
+	require.Equal(t, "sr1", svcReq.Id)
+}
+
+func TestSearchTyped(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/Observation", r.URL.Path)
+		io.WriteString(w, `{"resourceType": "Bundle", "entry": [
+			{"resource": {"resourceType": "Observation", "id": "o1", "valueQuantity": {"value": 1.5, "unit": "cm"}}},
+			{"resource": {"resourceType": "Patient", "id": "p1"}, "search": {"mode": "include"}},
+			{"resource": {"resourceType": "Observation", "id": "o2", "valueString": "normal"}}
+		]}`)
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+
+	got := make([]Observation, 0)
+	for obs, err := range Search[Observation](context.Background(), client, url.Values{}) {
+		require.NoError(t, err)
+		got = append(got, obs)
+	}
+
+	require.Len(t, got, 2)
+	require.Equal(t, "o1", got[0].Id)
+	require.Equal(t, json.Number("1.5"), got[0].ValueQuantity.Value)
+	require.Equal(t, "normal", got[1].ValueString)
+}
+
+func TestTypedPointer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/Patient/123":
+			io.WriteString(w, `{"resourceType": "Patient", "id": "123"}`)
+		case "/Task":
+			if r.Method == http.MethodPost {
+				io.WriteString(w, `{"resourceType": "Task", "id": "t2"}`)
+				return
+			}
+			io.WriteString(w, `{"resourceType": "Bundle", "entry": [
+				{"resource": {"resourceType": "Task", "id": "t1"}}
+			]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+	ctx := context.Background()
+
+	patient, err := Read[*Patient](ctx, client, "123")
+	require.NoError(t, err)
+	require.Equal(t, "123", patient.Id)
+
+	got := make([]*Task, 0)
+	for task, err := range Search[*Task](ctx, client, url.Values{}) {
+		require.NoError(t, err)
+		got = append(got, task)
+	}
+	require.Len(t, got, 1)
+	require.Equal(t, "t1", got[0].Id)
+
+	task, err := Create(ctx, client, &Task{ResourceType: "Task"})
+	require.NoError(t, err)
+	require.Equal(t, "t2", task.Id)
+}
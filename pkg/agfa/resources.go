@@ -0,0 +1,285 @@
+package agfa
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type Patient struct {
+	ResourceType         string               `json:"resourceType,omitzero"`
+	Id                   string               `json:"id,omitzero"`
+	Meta                 ResourceMeta         `json:"meta,omitzero"`
+	Identifier           []ResourceIdentifier `json:"identifier,omitzero"`
+	Active               *bool                `json:"active,omitzero"`
+	Name                 []HumanName          `json:"name,omitzero"`
+	Telecom              []ContactPoint       `json:"telecom,omitzero"`
+	Gender               string               `json:"gender,omitzero"`
+	BirthDate            string               `json:"birthDate,omitzero"`
+	Address              []Address            `json:"address,omitzero"`
+	ManagingOrganization Reference            `json:"managingOrganization,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type Encounter struct {
+	ResourceType    string                 `json:"resourceType,omitzero"`
+	Id              string                 `json:"id,omitzero"`
+	Meta            ResourceMeta           `json:"meta,omitzero"`
+	Identifier      []ResourceIdentifier   `json:"identifier,omitzero"`
+	Status          string                 `json:"status,omitzero"`
+	Class           Coding                 `json:"class,omitzero"`
+	Type            []Code                 `json:"type,omitzero"`
+	Priority        Code                   `json:"priority,omitzero"`
+	Subject         Reference              `json:"subject,omitzero"`
+	Participant     []EncounterParticipant `json:"participant,omitzero"`
+	Period          Period                 `json:"period,omitzero"`
+	ReasonCode      []Code                 `json:"reasonCode,omitzero"`
+	Location        []EncounterLocation    `json:"location,omitzero"`
+	ServiceProvider Reference              `json:"serviceProvider,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type EncounterParticipant struct {
+	Type       []Code    `json:"type,omitzero"`
+	Period     Period    `json:"period,omitzero"`
+	Individual Reference `json:"individual,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type EncounterLocation struct {
+	Location Reference `json:"location,omitzero"`
+	Status   string    `json:"status,omitzero"`
+	Period   Period    `json:"period,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type ImagingStudy struct {
+	ResourceType      string               `json:"resourceType,omitzero"`
+	Id                string               `json:"id,omitzero"`
+	Meta              ResourceMeta         `json:"meta,omitzero"`
+	Identifier        []ResourceIdentifier `json:"identifier,omitzero"`
+	Status            string               `json:"status,omitzero"`
+	Modality          []Coding             `json:"modality,omitzero"`
+	Subject           Reference            `json:"subject,omitzero"`
+	Encounter         Reference            `json:"encounter,omitzero"`
+	Started           string               `json:"started,omitzero"`
+	BasedOn           []Reference          `json:"basedOn,omitzero"`
+	Referrer          Reference            `json:"referrer,omitzero"`
+	Endpoint          []Reference          `json:"endpoint,omitzero"`
+	NumberOfSeries    *int                 `json:"numberOfSeries,omitzero"`
+	NumberOfInstances *int                 `json:"numberOfInstances,omitzero"`
+	ProcedureCode     []Code               `json:"procedureCode,omitzero"`
+	ReasonCode        []Code               `json:"reasonCode,omitzero"`
+	Description       string               `json:"description,omitzero"`
+	Series            []ImagingStudySeries `json:"series,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type ImagingStudySeries struct {
+	Uid               string                 `json:"uid,omitzero"`
+	Number            *int                   `json:"number,omitzero"`
+	Modality          Coding                 `json:"modality,omitzero"`
+	Description       string                 `json:"description,omitzero"`
+	NumberOfInstances *int                   `json:"numberOfInstances,omitzero"`
+	BodySite          Coding                 `json:"bodySite,omitzero"`
+	Started           string                 `json:"started,omitzero"`
+	Instance          []ImagingStudyInstance `json:"instance,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type ImagingStudyInstance struct {
+	Uid      string `json:"uid,omitzero"`
+	SopClass Coding `json:"sopClass,omitzero"`
+	Number   *int   `json:"number,omitzero"`
+	Title    string `json:"title,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type Practitioner struct {
+	ResourceType string               `json:"resourceType,omitzero"`
+	Id           string               `json:"id,omitzero"`
+	Meta         ResourceMeta         `json:"meta,omitzero"`
+	Identifier   []ResourceIdentifier `json:"identifier,omitzero"`
+	Active       *bool                `json:"active,omitzero"`
+	Name         []HumanName          `json:"name,omitzero"`
+	Telecom      []ContactPoint       `json:"telecom,omitzero"`
+	Address      []Address            `json:"address,omitzero"`
+	Gender       string               `json:"gender,omitzero"`
+	BirthDate    string               `json:"birthDate,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type Organization struct {
+	ResourceType string               `json:"resourceType,omitzero"`
+	Id           string               `json:"id,omitzero"`
+	Meta         ResourceMeta         `json:"meta,omitzero"`
+	Identifier   []ResourceIdentifier `json:"identifier,omitzero"`
+	Active       *bool                `json:"active,omitzero"`
+	Type         []Code               `json:"type,omitzero"`
+	Name         string               `json:"name,omitzero"`
+	Alias        []string             `json:"alias,omitzero"`
+	Telecom      []ContactPoint       `json:"telecom,omitzero"`
+	Address      []Address            `json:"address,omitzero"`
+	PartOf       Reference            `json:"partOf,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type DiagnosticReport struct {
+	ResourceType       string               `json:"resourceType,omitzero"`
+	Id                 string               `json:"id,omitzero"`
+	Meta               ResourceMeta         `json:"meta,omitzero"`
+	Identifier         []ResourceIdentifier `json:"identifier,omitzero"`
+	BasedOn            []Reference          `json:"basedOn,omitzero"`
+	Status             string               `json:"status,omitzero"`
+	Category           []Code               `json:"category,omitzero"`
+	Code               Code                 `json:"code,omitzero"`
+	Subject            Reference            `json:"subject,omitzero"`
+	Encounter          Reference            `json:"encounter,omitzero"`
+	EffectiveDateTime  string               `json:"effectiveDateTime,omitzero"`
+	Issued             string               `json:"issued,omitzero"`
+	Performer          []Reference          `json:"performer,omitzero"`
+	ResultsInterpreter []Reference          `json:"resultsInterpreter,omitzero"`
+	Result             []Reference          `json:"result,omitzero"`
+	ImagingStudy       []Reference          `json:"imagingStudy,omitzero"`
+	Conclusion         string               `json:"conclusion,omitzero"`
+	ConclusionCode     []Code               `json:"conclusionCode,omitzero"`
+	PresentedForm      []Attachment         `json:"presentedForm,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type Observation struct {
+	ResourceType         string                 `json:"resourceType,omitzero"`
+	Id                   string                 `json:"id,omitzero"`
+	Meta                 ResourceMeta           `json:"meta,omitzero"`
+	Identifier           []ResourceIdentifier   `json:"identifier,omitzero"`
+	BasedOn              []Reference            `json:"basedOn,omitzero"`
+	Status               string                 `json:"status,omitzero"`
+	Category             []Code                 `json:"category,omitzero"`
+	Code                 Code                   `json:"code,omitzero"`
+	Subject              Reference              `json:"subject,omitzero"`
+	Encounter            Reference              `json:"encounter,omitzero"`
+	EffectiveDateTime    string                 `json:"effectiveDateTime,omitzero"`
+	Issued               string                 `json:"issued,omitzero"`
+	Performer            []Reference            `json:"performer,omitzero"`
+	ValueQuantity        *Quantity              `json:"valueQuantity,omitzero"`
+	ValueCodeableConcept *Code                  `json:"valueCodeableConcept,omitzero"`
+	ValueString          string                 `json:"valueString,omitzero"`
+	Interpretation       []Code                 `json:"interpretation,omitzero"`
+	Note                 []Annotation           `json:"note,omitzero"`
+	BodySite             Code                   `json:"bodySite,omitzero"`
+	DerivedFrom          []Reference            `json:"derivedFrom,omitzero"`
+	Component            []ObservationComponent `json:"component,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type ObservationComponent struct {
+	Code                 Code      `json:"code,omitzero"`
+	ValueQuantity        *Quantity `json:"valueQuantity,omitzero"`
+	ValueCodeableConcept *Code     `json:"valueCodeableConcept,omitzero"`
+	ValueString          string    `json:"valueString,omitzero"`
+	Interpretation       []Code    `json:"interpretation,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type HumanName struct {
+	Use    string   `json:"use,omitzero"`
+	Text   string   `json:"text,omitzero"`
+	Family string   `json:"family,omitzero"`
+	Given  []string `json:"given,omitzero"`
+	Prefix []string `json:"prefix,omitzero"`
+	Suffix []string `json:"suffix,omitzero"`
+	Period Period   `json:"period,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+func (hn HumanName) String() string {
+	if hn.Text != "" {
+		return hn.Text
+	}
+
+	parts := make([]string, 0, len(hn.Prefix)+len(hn.Given)+len(hn.Suffix)+1)
+	parts = append(parts, hn.Prefix...)
+	parts = append(parts, hn.Given...)
+	if hn.Family != "" {
+		parts = append(parts, hn.Family)
+	}
+	parts = append(parts, hn.Suffix...)
+
+	return strings.Join(parts, " ")
+}
+
+type ContactPoint struct {
+	System string `json:"system,omitzero"`
+	Value  string `json:"value,omitzero"`
+	Use    string `json:"use,omitzero"`
+	Rank   *int   `json:"rank,omitzero"`
+	Period Period `json:"period,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type Address struct {
+	Use        string   `json:"use,omitzero"`
+	Type       string   `json:"type,omitzero"`
+	Text       string   `json:"text,omitzero"`
+	Line       []string `json:"line,omitzero"`
+	City       string   `json:"city,omitzero"`
+	District   string   `json:"district,omitzero"`
+	State      string   `json:"state,omitzero"`
+	PostalCode string   `json:"postalCode,omitzero"`
+	Country    string   `json:"country,omitzero"`
+	Period     Period   `json:"period,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type Period struct {
+	Start string `json:"start,omitzero"`
+	End   string `json:"end,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type Quantity struct {
+	Value      json.Number `json:"value,omitzero"`
+	Comparator string      `json:"comparator,omitzero"`
+	Unit       string      `json:"unit,omitzero"`
+	System     string      `json:"system,omitzero"`
+	Code       string      `json:"code,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type Attachment struct {
+	ContentType string `json:"contentType,omitzero"`
+	Language    string `json:"language,omitzero"`
+	Data        string `json:"data,omitzero"`
+	Url         string `json:"url,omitzero"`
+	Size        *int   `json:"size,omitzero"`
+	Hash        string `json:"hash,omitzero"`
+	Title       string `json:"title,omitzero"`
+	Creation    string `json:"creation,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type Annotation struct {
+	AuthorReference Reference `json:"authorReference,omitzero"`
+	AuthorString    string    `json:"authorString,omitzero"`
+	Time            string    `json:"time,omitzero"`
+	Text            string    `json:"text,omitzero"`
+
+	Extras Extras `json:"-"`
+}
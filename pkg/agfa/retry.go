@@ -0,0 +1,134 @@
+package agfa
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how idempotent requests are retried after transient
+// failures. The zero value disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first one.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry; it doubles on every
+	// subsequent attempt and is randomized by up to half.
+	BaseDelay time.Duration
+	// MaxDelay caps both the backoff and any Retry-After the server sends;
+	// zero leaves them uncapped.
+	MaxDelay time.Duration
+	// RetryableStatus lists the response codes worth trying again.
+	RetryableStatus []int
+}
+
+// DefaultRetryPolicy tries a request up to three times, backing off from
+// 250ms, when the server is throttling or its gateway is failing.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   250 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+	RetryableStatus: []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+// WithRetryPolicy sets the retry policy applied to GET requests.
+func WithRetryPolicy(policy RetryPolicy) func(*Client) {
+	return func(client *Client) {
+		client.retry = policy
+	}
+}
+
+func (policy RetryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) &&
+			!errors.Is(err, context.DeadlineExceeded) &&
+			!errors.Is(err, ErrSessionExpired)
+	}
+
+	return slices.Contains(policy.RetryableStatus, resp.StatusCode)
+}
+
+// delay returns how long to wait after the given (1-based) failed attempt.
+func (policy RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return policy.capDelay(d)
+		}
+	}
+
+	d := time.Duration(math.MaxInt64)
+	if shift := attempt - 1; shift < 63 && policy.BaseDelay <= d>>shift {
+		d = policy.BaseDelay << shift
+	}
+	d = policy.capDelay(d)
+
+	if half := int64(d / 2); half > 0 {
+		d = time.Duration(half + rand.Int64N(half+1)) // #nosec G404 -- jitter only
+	}
+
+	return d
+}
+
+func (policy RetryPolicy) capDelay(d time.Duration) time.Duration {
+	if policy.MaxDelay > 0 {
+		return min(d, policy.MaxDelay)
+	}
+
+	return d
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+
+	return 0, false
+}
+
+// doRetry sends req via do, retrying according to the client's policy.
+// Only use it for idempotent requests.
+func (client *Client) doRetry(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	policy := client.retry
+
+	for attempt := 1; ; attempt++ {
+		resp, err := client.do(cloneRequest(req).WithContext(withAttempt(ctx, attempt)))
+		if attempt >= policy.MaxAttempts || !policy.retryable(resp, err) {
+			return resp, err
+		}
+
+		wait := policy.delay(attempt, resp)
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
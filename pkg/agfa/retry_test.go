@@ -0,0 +1,113 @@
+package agfa
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientGet_Retry(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if hits < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"foo": "bar"}`)
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+	WithRetryPolicy(RetryPolicy{
+		MaxAttempts:     3,
+		BaseDelay:       time.Millisecond,
+		MaxDelay:        time.Millisecond,
+		RetryableStatus: []int{http.StatusServiceUnavailable},
+	})(client)
+
+	var got objStruct
+	err := client.Get("Task/1", nil, &got)
+	require.NoError(t, err)
+	require.Equal(t, "bar", got.Foo)
+	require.Equal(t, 3, hits)
+}
+
+func TestClientGet_RetryExhausted(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+	WithRetryPolicy(DefaultRetryPolicy)(client)
+
+	var got objStruct
+	err := client.Get("Task/1", nil, &got)
+	require.True(t, IsRateLimited(err))
+	require.Equal(t, DefaultRetryPolicy.MaxAttempts, hits)
+}
+
+func TestClientGet_NoRetryOnNotFound(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+	WithRetryPolicy(DefaultRetryPolicy)(client)
+
+	var got objStruct
+	err := client.Get("Task/1", nil, &got)
+	require.True(t, IsNotFound(err))
+	require.Equal(t, 1, hits)
+}
+
+func TestRetryAfter(t *testing.T) {
+	d, ok := retryAfter("7")
+	require.True(t, ok)
+	require.Equal(t, 7*time.Second, d)
+
+	d, ok = retryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	require.True(t, ok)
+	require.Zero(t, d)
+
+	_, ok = retryAfter("soon")
+	require.False(t, ok)
+
+	_, ok = retryAfter("")
+	require.False(t, ok)
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt := 1; attempt <= 6; attempt++ {
+		d := policy.delay(attempt, nil)
+		require.LessOrEqual(t, d, time.Second)
+		require.Positive(t, d)
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {"120"}}}
+	require.Equal(t, time.Second, policy.delay(1, resp))
+}
+
+func TestRetryPolicy_DelayUncapped(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second}
+
+	require.GreaterOrEqual(t, policy.delay(1, nil), 500*time.Millisecond)
+	require.GreaterOrEqual(t, policy.delay(4, nil), 4*time.Second)
+	require.Positive(t, policy.delay(100, nil))
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {"120"}}}
+	require.Equal(t, 2*time.Minute, policy.delay(1, resp))
+}
@@ -0,0 +1,110 @@
+package agfa
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+)
+
+// SearchOptions caps how much of a result set Search pulls. Zero values mean
+// no limit.
+type SearchOptions struct {
+	MaxPages   int
+	MaxResults int
+}
+
+func WithMaxPages(n int) func(*SearchOptions) {
+	return func(o *SearchOptions) {
+		o.MaxPages = n
+	}
+}
+
+func WithMaxResults(n int) func(*SearchOptions) {
+	return func(o *SearchOptions) {
+		o.MaxResults = n
+	}
+}
+
+// Search runs a FHIR search against resourceType and yields every entry,
+// following the Bundle's next links across pages. Iteration stops at the
+// first error, which is yielded along with an empty entry.
+func (client *Client) Search(ctx context.Context, resourceType string, params url.Values, opts ...func(*SearchOptions)) iter.Seq2[SearchEntry, error] {
+	var o SearchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	u := client.reqUrl(resourceType)
+	u.RawQuery = params.Encode()
+
+	return client.pages(ctx, u, o)
+}
+
+// pages yields the entries of the Bundle at u and of every page after it.
+func (client *Client) pages(ctx context.Context, u *url.URL, o SearchOptions) iter.Seq2[SearchEntry, error] {
+	return func(yield func(SearchEntry, error) bool) {
+		results := 0
+		for page := 1; ; page++ {
+			bundle, err := client.searchPage(ctx, u)
+			if err != nil {
+				yield(SearchEntry{}, err)
+				return
+			}
+
+			for _, entry := range bundle.Entry {
+				if !yield(entry, nil) {
+					return
+				}
+
+				results++
+				if o.MaxResults > 0 && results >= o.MaxResults {
+					return
+				}
+			}
+
+			next := nextLink(bundle.Link)
+			if next == "" || (o.MaxPages > 0 && page >= o.MaxPages) {
+				return
+			}
+
+			if u, err = client.nextPageUrl(next); err != nil {
+				yield(SearchEntry{}, err)
+				return
+			}
+		}
+	}
+}
+
+func (client *Client) searchPage(ctx context.Context, u *url.URL) (rawBundle, error) {
+	resp, err := client.get(ctx, u)
+	if err != nil {
+		return rawBundle{}, err
+	}
+	defer resp.Body.Close()
+
+	var bundle rawBundle
+	if err = decode(resp.Body, &bundle); err != nil {
+		return rawBundle{}, fmt.Errorf("decode search page: %v", err)
+	}
+
+	return bundle, nil
+}
+
+// nextPageUrl resolves a next link against the base URL, refusing to follow
+// it to another host, or from https to http, along with the client's
+// credentials.
+func (client *Client) nextPageUrl(next string) (*url.URL, error) {
+	base := client.reqUrl()
+
+	u, err := base.Parse(next)
+	if err != nil {
+		return nil, fmt.Errorf("invalid next link %q: %v", next, err)
+	}
+
+	if u.Scheme != base.Scheme || u.Host != base.Host {
+		return nil, fmt.Errorf("next link %q points outside %s://%s", next, base.Scheme, base.Host)
+	}
+
+	return u, nil
+}
@@ -0,0 +1,127 @@
+package agfa
+
+import (
+	"context"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newPagedServer(t *testing.T, next func(page string) string) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/Task", r.URL.Path)
+		require.Equal(t, "ready", r.URL.Query().Get("status"))
+
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+
+		link := ""
+		if n := next(page); n != "" {
+			link = `{"relation": "next", "url": "` + n + `"}`
+		}
+
+		io.WriteString(w, `{
+			"resourceType": "Bundle",
+			"type": "searchset",
+			"link": [`+link+`],
+			"entry": [
+				{"fullUrl": "Task/`+page+`a", "resource": {"resourceType": "Task", "id": "`+page+`a"}},
+				{"fullUrl": "Task/`+page+`b", "resource": {"resourceType": "Task", "id": "`+page+`b"}}
+			]
+		}`)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func collectIds(t *testing.T, seq iter.Seq2[SearchEntry, error]) ([]string, error) {
+	t.Helper()
+
+	ids := make([]string, 0)
+	for entry, err := range seq {
+		if err != nil {
+			return ids, err
+		}
+
+		var task Task
+		require.NoError(t, entry.Decode(&task))
+		require.Equal(t, "Task", entry.ResourceType())
+		ids = append(ids, task.Id)
+	}
+
+	return ids, nil
+}
+
+func TestClientSearch(t *testing.T) {
+	var ts *httptest.Server
+	ts = newPagedServer(t, func(page string) string {
+		switch page {
+		case "1":
+			return ts.URL + "/Task?status=ready&page=2"
+		case "2":
+			return "Task?status=ready&page=3"
+		}
+		return ""
+	})
+
+	client := newClientWithServer(ts)
+	params := url.Values{"status": {"ready"}}
+
+	ids, err := collectIds(t, client.Search(context.Background(), "Task", params))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1a", "1b", "2a", "2b", "3a", "3b"}, ids)
+
+	ids, err = collectIds(t, client.Search(context.Background(), "Task", params, WithMaxPages(2)))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1a", "1b", "2a", "2b"}, ids)
+
+	ids, err = collectIds(t, client.Search(context.Background(), "Task", params, WithMaxResults(3)))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1a", "1b", "2a"}, ids)
+}
+
+func TestClientSearch_ForeignNextLink(t *testing.T) {
+	ts := newPagedServer(t, func(page string) string {
+		if page == "1" {
+			return "https://elsewhere.example.com/Task?page=2"
+		}
+		return ""
+	})
+
+	client := newClientWithServer(ts)
+
+	ids, err := collectIds(t, client.Search(context.Background(), "Task", url.Values{"status": {"ready"}}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "points outside")
+	require.Equal(t, []string{"1a", "1b"}, ids)
+}
+
+func TestBundle_Next(t *testing.T) {
+	b := Bundle{Link: []BundleLink{{Relation: "self", Url: "a"}, {Relation: "next", Url: "b"}}}
+	require.Equal(t, "b", b.Next())
+	require.Empty(t, Bundle{}.Next())
+}
+
+func TestNextPageUrl(t *testing.T) {
+	client := NewClient("https://agfa.example.com")
+
+	u, err := client.nextPageUrl("Task?page=2")
+	require.NoError(t, err)
+	require.Equal(t, "https://agfa.example.com/Task?page=2", u.String())
+
+	_, err = client.nextPageUrl("http://agfa.example.com/Task?page=2")
+	require.ErrorContains(t, err, "points outside https://agfa.example.com")
+
+	_, err = client.nextPageUrl("https://elsewhere.example.com/Task?page=2")
+	require.ErrorContains(t, err, "points outside")
+}
@@ -1,67 +1,192 @@
 package agfa
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/s-hammon/p"
 )
 
 type SessionParams struct {
 	ClientId string
 	Username string
 	Password string
+	// FormId is the id of the login form, for login pages with more than
+	// one form. By default the first form with a password field is used.
+	FormId string
+	// OTP supplies the one-time code when the identity provider asks for
+	// one after the password, e.g. TOTP("BASE32SECRET") or a function
+	// prompting the user. Logins with MFA fail with ErrOTPRequired
+	// without it.
+	OTP func(ctx context.Context) (string, error)
 }
 
 // This is a hacky way to create a session with your client by impersonating
 // someone logging into the remote system thru their browser. :^)
 // Will fail if any part of the auth pipeline returns something "unexpected".
 func (client *Client) Session(params SessionParams) (*Client, error) {
+	return client.SessionContext(context.Background(), params)
+}
+
+// SessionContext is like Session, but every request in the login flow is
+// bound to ctx. It installs a SessionAuthenticator, so the client logs in
+// again on its own once the session expires.
+func (client *Client) SessionContext(ctx context.Context, params SessionParams) (*Client, error) {
 	client.ClientId = params.ClientId
 	client.User = params.Username
 	client.Pass = params.Password
+	client.auth = &SessionAuthenticator{Params: params}
+
+	if err := client.Login(ctx); err != nil {
+		return nil, err
+	}
 
-	base := strings.TrimRight(client.BaseUrl, "/")
+	return client, nil
+}
+
+// SessionAuthenticator logs in through the identity provider's HTML login
+// form, the way a browser would, and keeps the session cookies in the
+// client's cookie jar. Refresh simply logs in again.
+type SessionAuthenticator struct {
+	Params SessionParams
+}
+
+func (a *SessionAuthenticator) Authenticate(ctx context.Context, client *Client) error {
+	client.redactor.AddSecret(a.Params.Password)
+	base := client.Base()
 
 	// get redirect url from initial request attempt
-	url, err := client.getRedirectUrl(base)
+	url, err := client.getRedirectUrl(ctx, base)
 	if err != nil {
-		return nil, fmt.Errorf("auth.getRedirectUrl: %v", err)
+		return fmt.Errorf("auth.getRedirectUrl: %v", err)
 	}
 
 	// follow login redirect
-	resp, err := client.hc.Get(url)
+	resp, err := client.fetch(ctx, resolveUrl(base, url))
 	if err != nil {
-		return nil, fmt.Errorf("login request failed: %v", err)
+		return fmt.Errorf("login request failed: %v", err)
 	}
 
 	// generate form submission request
-	req, err := newFormRequest(client.User, client.Pass, resp)
+	req, err := newFormRequest(a.Params, resp)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	// submit form & obtain redirect
+	// submit form & obtain redirect, answering an OTP challenge on the way
 	url, err = client.getAuthRedirect(req)
+	var challenge *otpChallenge
+	if errors.As(err, &challenge) {
+		if req, err = a.otpRequest(ctx, challenge); err != nil {
+			return err
+		}
+		url, err = client.getAuthRedirect(req)
+		if errors.As(err, &challenge) {
+			// Keycloak shows the OTP form again if the code was wrong
+			msg := p.Coalesce(challenge.message, "one-time code rejected")
+			return &LoginError{StatusCode: http.StatusOK, Message: msg}
+		}
+	}
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	resp, err = client.hc.Get(url)
+	resp, err = client.fetch(ctx, resolveUrl(req.URL.String(), url))
 	if err != nil {
-		return nil, err
+		return err
 	}
 	resp.Body.Close()
 
 	if !strings.HasPrefix(resp.Request.URL.String(), base) {
-		return nil, errors.New("could not resolve session-based authorization")
+		return errors.New("could not resolve session-based authorization")
 	}
 
-	return client, nil
+	return nil
+}
+
+// otpRequest fills in the OTP form with a code from Params.OTP.
+func (a *SessionAuthenticator) otpRequest(ctx context.Context, challenge *otpChallenge) (*http.Request, error) {
+	if a.Params.OTP == nil {
+		return nil, ErrOTPRequired
+	}
+
+	code, err := a.Params.OTP(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("one-time code: %w", err)
+	}
+
+	field, _ := challenge.form.input(otpFields...)
+	return newFormPost(ctx, challenge.pageUrl, challenge.form, url.Values{field.Name: {code}})
+}
+
+func (a *SessionAuthenticator) Refresh(ctx context.Context, client *Client) error {
+	return a.Authenticate(ctx, client)
+}
+
+// Apply is a no-op: the session cookies are sent by the cookie jar.
+func (a *SessionAuthenticator) Apply(*Client, *http.Request) error { return nil }
+
+// relogin refreshes the client's credentials, unless another goroutine
+// already did so since gen was observed.
+func (client *Client) relogin(ctx context.Context, gen uint64) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.sessionGen != gen {
+		return nil
+	}
+
+	err := client.auth.Refresh(ctx, client)
+	if err == nil {
+		client.sessionGen++
+	}
+	if client.onRelogin != nil {
+		client.onRelogin(err)
+	}
+
+	return err
+}
+
+// canRelogin reports whether the client has an authenticator to renew its
+// credentials with.
+func (client *Client) canRelogin() bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	return client.auth != nil
+}
+
+// generation returns a counter that is bumped on every successful login.
+func (client *Client) generation() uint64 {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	return client.sessionGen
+}
+
+// sessionExpired reports whether resp came from the identity provider
+// rather than the FHIR server: an explicit 401, a redirect (followed or
+// not) away from the base URL, or a successful HTML page where FHIR JSON
+// was expected. HTML error pages, e.g. a gateway's 502, are left to the
+// retry policy.
+func (client *Client) sessionExpired(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusFound, http.StatusSeeOther:
+		return true
+	}
+
+	if resp.Request != nil && !strings.HasPrefix(resp.Request.URL.String(), client.Base()) {
+		return true
+	}
+
+	return resp.StatusCode/100 == 2 && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html")
 }
 
-func (client *Client) getRedirectUrl(base string) (string, error) {
+func (client *Client) getRedirectUrl(ctx context.Context, base string) (string, error) {
 	path := "/List"
 	if client.RedirectListId != "" {
 		path = "/List?_id=" + client.RedirectListId
@@ -69,10 +194,12 @@ func (client *Client) getRedirectUrl(base string) (string, error) {
 
 	initUrl := base + path
 
-	reset := client.disableCheckRedirect()
-	defer reset()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, initUrl, nil)
+	if err != nil {
+		return "", fmt.Errorf("init.Get: %v", err)
+	}
 
-	resp, err := client.hc.Get(initUrl)
+	resp, err := client.noRedirect().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("init.Get: %v", err)
 	}
@@ -90,39 +217,25 @@ func (client *Client) getRedirectUrl(base string) (string, error) {
 	return redirectUrl, nil
 }
 
-func newForm(user, pass string) string {
-	form := url.Values{}
-	form.Set("username", user)
-	form.Set("password", pass)
-	form.Set("login", "Sign In")
-
-	return form.Encode()
-}
-
-func getActionUrl(resp *http.Response) (string, error) {
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("login page returned %d", resp.StatusCode)
-	}
-
-	actionUrl, _, err := extractForm(resp)
-	if err != nil {
-		return "", fmt.Errorf("extractForm: %v", err)
-	}
-
-	return actionUrl, nil
-}
-
 func (client *Client) getAuthRedirect(req *http.Request) (string, error) {
-	reset := client.disableCheckRedirect()
-	defer reset()
-
-	postResp, err := client.hc.Do(req)
+	postResp, err := client.noRedirect().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("postResp.Do: %v", err)
 	}
 	defer postResp.Body.Close()
 
 	if postResp.StatusCode != http.StatusFound && postResp.StatusCode != http.StatusSeeOther {
+		// a rejected login shows the form again, with the reason on it,
+		// while accounts with MFA get a form asking for a one-time code
+		if strings.HasPrefix(postResp.Header.Get("Content-Type"), "text/html") {
+			page := parsePage(postResp.Body)
+			if form, ok := page.otpForm(); ok {
+				return "", &otpChallenge{form: form, pageUrl: postResp.Request.URL, message: page.Error}
+			}
+			if page.Error != "" {
+				return "", &LoginError{StatusCode: postResp.StatusCode, Message: page.Error}
+			}
+		}
 		return "", fmt.Errorf("login submission failed: expected redirect, got %d", postResp.StatusCode)
 	}
 
@@ -134,27 +247,101 @@ func (client *Client) getAuthRedirect(req *http.Request) (string, error) {
 	return authRedirect, nil
 }
 
-func newFormRequest(user, pass string, resp *http.Response) (*http.Request, error) {
+// newFormRequest fills in the login form on the page in resp.
+func newFormRequest(params SessionParams, resp *http.Response) (*http.Request, error) {
 	defer resp.Body.Close()
 
-	url, err := getActionUrl(resp)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("newFormRequest: login page returned %d", resp.StatusCode)
+	}
+
+	form, err := parsePage(resp.Body).loginForm(params.FormId)
 	if err != nil {
-		return nil, fmt.Errorf("newFormRequest: %v", err)
+		return nil, fmt.Errorf("newFormRequest: %w", err)
 	}
 
-	payload := newForm(user, pass)
+	userField := "username"
+	if in, ok := form.input("username", "email", "text"); ok && in.Name != "" {
+		userField = in.Name
+	}
+	passField := "password"
+	if in, ok := form.input("password"); ok && in.Name != "" {
+		passField = in.Name
+	}
 
-	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(payload))
+	req, err := newFormPost(resp.Request.Context(), resp.Request.URL, form, url.Values{
+		userField: {params.Username},
+		passField: {params.Password},
+	})
 	if err != nil {
 		return nil, fmt.Errorf("newFormRequest: %v", err)
 	}
 
-	origin := resp.Request.URL.Scheme + "://" + resp.Request.URL.Host
+	return req, nil
+}
+
+// newFormPost builds the submission of a form found on the page at
+// pageUrl: every hidden field is sent back as is, along with values and
+// the submit button.
+func newFormPost(ctx context.Context, pageUrl *url.URL, form htmlForm, values url.Values) (*http.Request, error) {
+	action, err := pageUrl.Parse(form.Action)
+	if err != nil {
+		return nil, fmt.Errorf("invalid form action %q: %v", form.Action, err)
+	}
+
+	payload := url.Values{}
+	for _, in := range form.Inputs {
+		if in.Type == "hidden" && in.Name != "" {
+			payload.Set(in.Name, in.Value)
+		}
+	}
+	for k, v := range values {
+		payload[k] = v
+	}
+
+	if in, ok := form.input("submit"); ok && in.Name != "" {
+		payload.Set(in.Name, in.Value)
+	} else {
+		payload.Set("login", "Sign In")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, action.String(), strings.NewReader(payload.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	origin := pageUrl.Scheme + "://" + pageUrl.Host
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Referer", resp.Request.URL.String())
+	req.Header.Set("Referer", pageUrl.String())
 	req.Header.Set("Origin", origin)
 	req.Header.Set("User-Agent", "Mozilla/5.0")
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
 
 	return req, nil
 }
+
+// resolveUrl resolves a possibly relative redirect location against the
+// url it came from.
+func resolveUrl(from, location string) string {
+	base, err := url.Parse(from)
+	if err != nil {
+		return location
+	}
+
+	u, err := base.Parse(location)
+	if err != nil {
+		return location
+	}
+
+	return u.String()
+}
+
+// fetch issues a plain GET bound to ctx, as a browser would.
+func (client *Client) fetch(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.hc.Do(req)
+}
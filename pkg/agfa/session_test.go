@@ -1,12 +1,14 @@
 package agfa
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -38,7 +40,7 @@ func TestGetRedirectUrl(t *testing.T) {
 
 	c := NewClient(ts.URL)
 
-	url, err := c.getRedirectUrl(strings.TrimRight(ts.URL, "/"))
+	url, err := c.getRedirectUrl(context.Background(), strings.TrimRight(ts.URL, "/"))
 	require.NoError(t, err)
 	require.Equal(t, "/login", url)
 	require.Equal(t, "/List", capturedReq.URL.Path)
@@ -52,7 +54,7 @@ func TestGetRedirectUrl_NoRedirect(t *testing.T) {
 
 	c := NewClient(ts.URL)
 
-	_, err := c.getRedirectUrl(strings.TrimRight(ts.URL, "/"))
+	_, err := c.getRedirectUrl(context.Background(), strings.TrimRight(ts.URL, "/"))
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "expected 302")
 }
@@ -70,10 +72,11 @@ func TestNewFormRequest(t *testing.T) {
 		},
 	}
 
-	req, err := newFormRequest("testuser", "testpass", resp)
+	req, err := newFormRequest(SessionParams{Username: "testuser", Password: "testpass"}, resp)
 	require.NoError(t, err)
 	require.Equal(t, http.MethodPost, req.Method)
 	require.Equal(t, ep, req.URL.Path)
+	require.Equal(t, "example.com", req.URL.Host, "relative action should resolve against the page")
 
 	require.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
 	require.Equal(t, "https://example.com/login", req.Header.Get("Referer"))
@@ -85,6 +88,33 @@ func TestNewFormRequest(t *testing.T) {
 	require.Equal(t, "testuser", values.Get("username"))
 	require.Equal(t, "testpass", values.Get("password"))
 	require.Equal(t, "Sign In", values.Get("login"))
+	require.Equal(t, "abc", values.Get("session_code"))
+	require.Equal(t, "xyz", values.Get("execution"))
+	require.Equal(t, "my-client", values.Get("client_id"))
+}
+
+func TestSession_InvalidCredentials(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/login":
+			io.WriteString(w, buildLoginHTML("submit"))
+		case "/submit":
+			io.WriteString(w, `<html><body>
+				<span id="input-error" class="kc-feedback-text">Invalid username or password.</span>
+				`+buildLoginHTML("submit")+`</body></html>`)
+		default:
+			http.Redirect(w, r, "/login", http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	_, err := NewClient(ts.URL).Session(SessionParams{Username: "user", Password: "wrong"})
+	var loginErr *LoginError
+	require.ErrorAs(t, err, &loginErr)
+	require.Equal(t, "Invalid username or password.", loginErr.Message)
+	require.Equal(t, http.StatusOK, loginErr.StatusCode)
 }
 
 func TestGetAuthRedirect(t *testing.T) {
@@ -114,3 +144,97 @@ func TestGetAuthRedirect_NoLocation(t *testing.T) {
 	_, err := c.getAuthRedirect(req)
 	require.Error(t, err)
 }
+
+// newFakeAgfa serves a minimal login flow: requests without a valid "sid"
+// cookie are redirected to an HTML login form, which hands out a new sid.
+func newFakeAgfa(t *testing.T) (*httptest.Server, *int, *string) {
+	t.Helper()
+
+	logins := 0
+	sid := ""
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, buildLoginHTML(ts.URL+"/submit"))
+		case "/submit":
+			logins++
+			sid = strings.Repeat("x", logins)
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: sid, Path: "/"})
+			http.Redirect(w, r, ts.URL+"/List", http.StatusFound)
+		default:
+			c, err := r.Cookie("sid")
+			if err != nil || c.Value != sid {
+				http.Redirect(w, r, ts.URL+"/login", http.StatusFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/fhir+json")
+			io.WriteString(w, `{"foo": "bar"}`)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, &logins, &sid
+}
+
+func TestSession_Relogin(t *testing.T) {
+	ts, logins, sid := newFakeAgfa(t)
+
+	var hookErrs []error
+	c := NewClient(ts.URL, WithReloginHook(func(err error) {
+		hookErrs = append(hookErrs, err)
+	}))
+
+	_, err := c.Session(SessionParams{Username: "user", Password: "pass"})
+	require.NoError(t, err)
+	require.Equal(t, 1, *logins)
+
+	// expire the session server-side
+	*sid = "expired"
+
+	var got objStruct
+	err = c.Get("Task/1", nil, &got)
+	require.NoError(t, err)
+	require.Equal(t, "bar", got.Foo)
+	require.Equal(t, 2, *logins)
+	require.Equal(t, []error{nil}, hookErrs)
+}
+
+func TestSession_ExpiredWithoutParams(t *testing.T) {
+	ts, _, _ := newFakeAgfa(t)
+
+	c := NewClient(ts.URL)
+
+	var got objStruct
+	err := c.Get("Task/1", nil, &got)
+	require.ErrorIs(t, err, ErrSessionExpired)
+}
+
+func TestClientGet_HTMLErrorPageRetried(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits++; hits == 1 {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, "<html><body>Bad Gateway</body></html>")
+			return
+		}
+		w.Header().Set("Content-Type", "application/fhir+json")
+		io.WriteString(w, `{"foo": "bar"}`)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, WithAuthenticator(BearerToken("secret")), WithRetryPolicy(RetryPolicy{
+		MaxAttempts:     2,
+		BaseDelay:       time.Millisecond,
+		MaxDelay:        time.Millisecond,
+		RetryableStatus: []int{http.StatusBadGateway},
+	}))
+
+	var got objStruct
+	require.NoError(t, c.Get("Task/1", nil, &got))
+	require.Equal(t, "bar", got.Foo)
+	require.Equal(t, 2, hits)
+}
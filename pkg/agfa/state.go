@@ -0,0 +1,162 @@
+package agfa
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionState is a snapshot of a client's credentials: its cookies and,
+// for OAuth2Authenticator, its current token. It can be persisted and
+// restored into a new client to skip logging in.
+type SessionState struct {
+	BaseUrl string         `json:"baseUrl"`
+	Cookies []StoredCookie `json:"cookies,omitempty"`
+	Token   *Token         `json:"token,omitempty"`
+	Saved   time.Time      `json:"saved"`
+}
+
+// StoredCookie is a cookie together with the url it was set for.
+type StoredCookie struct {
+	Url      string    `json:"url"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitzero"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"httpOnly,omitempty"`
+}
+
+// Expired reports whether every expiring credential in the state has
+// expired. Session cookies are assumed to be live.
+func (s SessionState) Expired() bool {
+	if s.Token != nil {
+		return s.Token.RefreshToken == "" && !s.Token.Valid()
+	}
+
+	return len(s.Cookies) == 0
+}
+
+// State returns a snapshot of the client's credentials.
+func (client *Client) State() SessionState {
+	state := SessionState{BaseUrl: client.BaseUrl, Saved: time.Now()}
+
+	if jar, ok := client.hc.Jar.(*recordingJar); ok {
+		state.Cookies = jar.stored()
+	}
+
+	if a, ok := client.auth.(*OAuth2Authenticator); ok {
+		a.mu.Lock()
+		if a.token.AccessToken != "" {
+			token := a.token
+			state.Token = &token
+		}
+		a.mu.Unlock()
+	}
+
+	return state
+}
+
+// Restore loads credentials saved with State into the client. Restored
+// credentials which turn out to be stale are refreshed like any others.
+func (client *Client) Restore(state SessionState) {
+	for _, sc := range state.Cookies {
+		u, err := url.Parse(sc.Url)
+		if err != nil {
+			continue
+		}
+		client.hc.Jar.SetCookies(u, []*http.Cookie{{
+			Name:     sc.Name,
+			Value:    sc.Value,
+			Domain:   sc.Domain,
+			Path:     sc.Path,
+			Expires:  sc.Expires,
+			Secure:   sc.Secure,
+			HttpOnly: sc.HttpOnly,
+		}})
+	}
+
+	if a, ok := client.auth.(*OAuth2Authenticator); ok && state.Token != nil {
+		a.mu.Lock()
+		a.setToken(client, *state.Token)
+		a.mu.Unlock()
+	}
+}
+
+// recordingJar is a cookie jar which remembers every cookie it was handed,
+// since cookiejar.Jar can't list its contents.
+type recordingJar struct {
+	*cookiejar.Jar
+
+	mu      sync.Mutex
+	cookies map[string]StoredCookie
+}
+
+func newRecordingJar() *recordingJar {
+	jar, _ := cookiejar.New(nil)
+	return &recordingJar{Jar: jar, cookies: map[string]StoredCookie{}}
+}
+
+func (j *recordingJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, c := range cookies {
+		sc := StoredCookie{
+			Url:      (&url.URL{Scheme: u.Scheme, Host: u.Host}).String(),
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if sc.Path == "" || !strings.HasPrefix(sc.Path, "/") {
+			sc.Path = defaultCookiePath(u.Path)
+		}
+		if c.MaxAge > 0 {
+			sc.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+		}
+
+		key := strings.Join([]string{u.Hostname(), strings.TrimPrefix(sc.Domain, "."), sc.Path, sc.Name}, "|")
+		if c.MaxAge < 0 || (!sc.Expires.IsZero() && sc.Expires.Before(time.Now())) {
+			delete(j.cookies, key)
+			continue
+		}
+		j.cookies[key] = sc
+	}
+}
+
+// stored returns the unexpired cookies.
+func (j *recordingJar) stored() []StoredCookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	cookies := make([]StoredCookie, 0, len(j.cookies))
+	for _, sc := range j.cookies {
+		if sc.Expires.IsZero() || sc.Expires.After(now) {
+			cookies = append(cookies, sc)
+		}
+	}
+
+	return cookies
+}
+
+// defaultCookiePath is the path a cookie without one applies to (RFC 6265
+// section 5.1.4).
+func defaultCookiePath(p string) string {
+	i := strings.LastIndex(p, "/")
+	if i <= 0 || p[0] != '/' {
+		return "/"
+	}
+
+	return p[:i]
+}
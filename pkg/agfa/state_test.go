@@ -0,0 +1,85 @@
+package agfa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientState_Restore(t *testing.T) {
+	ts, logins, _ := newFakeAgfa(t)
+
+	c := NewClient(ts.URL)
+	_, err := c.Session(SessionParams{Username: "user", Password: "pass"})
+	require.NoError(t, err)
+
+	// round trip through JSON, as a cache file would
+	b, err := json.Marshal(c.State())
+	require.NoError(t, err)
+	var state SessionState
+	require.NoError(t, json.Unmarshal(b, &state))
+	require.False(t, state.Expired())
+
+	restored := NewClient(ts.URL)
+	restored.Restore(state)
+
+	var got objStruct
+	require.NoError(t, restored.Get("Task/1", nil, &got))
+	require.Equal(t, "bar", got.Foo)
+	require.Equal(t, 1, *logins)
+}
+
+func TestClientState_Token(t *testing.T) {
+	ts, issued := newTokenServer(t, func(*http.Request) {})
+
+	grant := ClientCredentials{TokenUrl: ts.URL + "/token"}
+	c := NewClient(ts.URL, WithOAuth2(grant))
+	require.NoError(t, c.Login(context.Background()))
+
+	state := c.State()
+	require.NotNil(t, state.Token)
+	require.Equal(t, "tok-1", state.Token.AccessToken)
+
+	restored := NewClient(ts.URL, WithOAuth2(grant))
+	restored.Restore(state)
+
+	_, err := Read[Patient](context.Background(), restored, "p1")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, issued.Load())
+}
+
+func TestRecordingJar(t *testing.T) {
+	jar := newRecordingJar()
+	u, _ := url.Parse("https://agfa.example/fhir/r4/List")
+
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "sid", Value: "a"},
+		{Name: "lang", Value: "en", Path: "/", Expires: time.Now().Add(time.Hour)},
+		{Name: "old", Value: "x", Expires: time.Now().Add(-time.Hour)},
+	})
+	cookies := jar.stored()
+	require.Len(t, cookies, 2)
+
+	// deleting a cookie removes it from the record as well
+	jar.SetCookies(u, []*http.Cookie{{Name: "sid", MaxAge: -1}})
+	cookies = jar.stored()
+	require.Len(t, cookies, 1)
+	require.Equal(t, "lang", cookies[0].Name)
+}
+
+func TestDefaultCookiePath(t *testing.T) {
+	for in, want := range map[string]string{
+		"":              "/",
+		"/":             "/",
+		"/List":         "/",
+		"/fhir/r4/List": "/fhir/r4",
+		"/fhir/r4/":     "/fhir/r4",
+	} {
+		require.Equal(t, want, defaultCookiePath(in), in)
+	}
+}
@@ -0,0 +1,87 @@
+package agfa
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// WithCAFile trusts the PEM encoded CA certificates in path, e.g. a
+// hospital's internal CA, on top of the system roots.
+func WithCAFile(path string) func(*Client) {
+	return func(client *Client) {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			client.optErr = errors.Join(client.optErr, fmt.Errorf("tls: CA file: %v", err))
+			return
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			client.optErr = errors.Join(client.optErr, fmt.Errorf("tls: CA file %s: no certificates found", path))
+			return
+		}
+
+		client.tlsConf().RootCAs = pool
+	}
+}
+
+// WithRootCAs trusts exactly the CAs in pool.
+func WithRootCAs(pool *x509.CertPool) func(*Client) {
+	return func(client *Client) {
+		client.tlsConf().RootCAs = pool
+	}
+}
+
+// WithClientCertFile presents the PEM encoded certificate and key in
+// certFile and keyFile to servers asking for mutual TLS.
+func WithClientCertFile(certFile, keyFile string) func(*Client) {
+	return func(client *Client) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			client.optErr = errors.Join(client.optErr, fmt.Errorf("tls: client certificate: %v", err))
+			return
+		}
+
+		WithClientCertificate(cert)(client)
+	}
+}
+
+// WithClientCertificate presents cert to servers asking for mutual TLS.
+func WithClientCertificate(cert tls.Certificate) func(*Client) {
+	return func(client *Client) {
+		conf := client.tlsConf()
+		conf.Certificates = append(conf.Certificates, cert)
+	}
+}
+
+// WithMinTLSVersion refuses servers which can't speak at least version,
+// e.g. tls.VersionTLS13.
+func WithMinTLSVersion(version uint16) func(*Client) {
+	return func(client *Client) {
+		client.tlsConf().MinVersion = version
+	}
+}
+
+// WithInsecureSkipVerify turns off verification of the server's
+// certificate chain and host name, leaving the connection open to
+// interception. It is only meant for test systems with self-signed
+// certificates; prefer WithCAFile. It clears Client.VerifySsl.
+func WithInsecureSkipVerify() func(*Client) {
+	return func(client *Client) {
+		client.VerifySsl = false
+	}
+}
+
+func (client *Client) tlsConf() *tls.Config {
+	if client.tlsConfig == nil {
+		client.tlsConfig = &tls.Config{}
+	}
+
+	return client.tlsConfig
+}
@@ -0,0 +1,112 @@
+package agfa
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTLSServer(t *testing.T, conf func(*tls.Config)) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"foo": "bar"}`)
+	}))
+	ts.TLS = &tls.Config{}
+	if conf != nil {
+		conf(ts.TLS)
+	}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+// writePEM writes der to dir/name as a PEM block of the given type.
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+	return path
+}
+
+func TestTLS_CAFile(t *testing.T) {
+	ts := newTLSServer(t, nil)
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+
+	var got objStruct
+	err := NewClient(ts.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 1})).Get("Task/1", nil, &got)
+	require.ErrorContains(t, err, "certificate")
+
+	require.NoError(t, NewClient(ts.URL, WithCAFile(caFile)).Get("Task/1", nil, &got))
+	require.Equal(t, "bar", got.Foo)
+
+	c := NewClient(ts.URL, WithInsecureSkipVerify())
+	require.False(t, c.VerifySsl)
+	require.NoError(t, c.Get("Task/1", nil, &got))
+
+	err = NewClient(ts.URL, WithCAFile(filepath.Join(t.TempDir(), "missing.pem"))).Get("Task/1", nil, &got)
+	require.ErrorContains(t, err, "CA file")
+}
+
+func TestTLS_ClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "agfapi"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := writePEM(t, dir, "client.pem", "CERTIFICATE", der)
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	keyFile := writePEM(t, dir, "client.key", "PRIVATE KEY", keyDer)
+
+	ts := newTLSServer(t, func(conf *tls.Config) {
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+		conf.ClientCAs = x509.NewCertPool()
+		conf.ClientCAs.AddCert(cert)
+	})
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+
+	var got objStruct
+	err = NewClient(ts.URL, WithRootCAs(roots), WithRetryPolicy(RetryPolicy{MaxAttempts: 1})).Get("Task/1", nil, &got)
+	require.Error(t, err)
+
+	require.NoError(t, NewClient(ts.URL, WithRootCAs(roots), WithClientCertFile(certFile, keyFile)).Get("Task/1", nil, &got))
+	require.Equal(t, "bar", got.Foo)
+}
+
+func TestTLS_MinVersion(t *testing.T) {
+	ts := newTLSServer(t, func(conf *tls.Config) {
+		conf.MaxVersion = tls.VersionTLS12
+	})
+
+	var got objStruct
+	err := NewClient(ts.URL, WithInsecureSkipVerify(), WithMinTLSVersion(tls.VersionTLS13)).Get("Task/1", nil, &got)
+	require.ErrorContains(t, err, "protocol version")
+}
@@ -0,0 +1,277 @@
+package agfa
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/s-hammon/p"
+)
+
+// Transaction builds a batch or transaction Bundle. The server applies the
+// entries of a transaction all or nothing, and those of a batch one by one.
+// Builder errors are kept until the Bundle is encoded, e.g. by Submit:
+//
+//	tx := agfa.NewTransaction()
+//	for _, item := range worklist.Items {
+//		tx.Patch("Task", item.Task.Id, agfa.NewFHIRPatch().Replace("Task.status", inProgress))
+//	}
+//	resp, err := client.Submit(ctx, tx)
+type Transaction struct {
+	bundleType string
+	entries    []TransactionEntry
+	err        error
+}
+
+func NewTransaction() *Transaction {
+	return &Transaction{bundleType: "transaction"}
+}
+
+func NewBatch() *Transaction {
+	return &Transaction{bundleType: "batch"}
+}
+
+type TransactionEntry struct {
+	FullUrl  string              `json:"fullUrl,omitzero"`
+	Resource json.RawMessage     `json:"resource,omitzero"`
+	Request  BundleEntryRequest  `json:"request,omitzero"`
+	Response BundleEntryResponse `json:"response,omitzero"`
+}
+
+type BundleEntryRequest struct {
+	Method          string `json:"method,omitzero"`
+	Url             string `json:"url,omitzero"`
+	IfNoneMatch     string `json:"ifNoneMatch,omitzero"`
+	IfModifiedSince string `json:"ifModifiedSince,omitzero"`
+	IfMatch         string `json:"ifMatch,omitzero"`
+	IfNoneExist     string `json:"ifNoneExist,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type BundleEntryResponse struct {
+	Status       string          `json:"status,omitzero"`
+	Location     string          `json:"location,omitzero"`
+	Etag         string          `json:"etag,omitzero"`
+	LastModified string          `json:"lastModified,omitzero"`
+	Outcome      json.RawMessage `json:"outcome,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+// StatusCode parses the leading code of Status, e.g. 201 for "201 Created".
+func (r BundleEntryResponse) StatusCode() int {
+	code, _ := strconv.Atoi(strings.Fields(r.Status + " ")[0])
+	return code
+}
+
+func (tx *Transaction) Len() int {
+	return len(tx.entries)
+}
+
+func (tx *Transaction) add(method, u string, body json.RawMessage, o WriteOptions) *TransactionEntry {
+	entry := TransactionEntry{
+		Resource: body,
+		Request:  BundleEntryRequest{Method: method, Url: u},
+	}
+
+	if o.IfMatch != "" {
+		entry.Request.IfMatch = `W/"` + o.IfMatch + `"`
+	}
+	if len(o.IfNoneExist) != 0 {
+		entry.Request.IfNoneExist = o.IfNoneExist.Encode()
+	}
+
+	tx.entries = append(tx.entries, entry)
+	return &tx.entries[len(tx.entries)-1]
+}
+
+func (tx *Transaction) fail(err error) {
+	if tx.err == nil {
+		tx.err = fmt.Errorf("transaction entry %d: %v", len(tx.entries), err)
+	}
+}
+
+// Create adds a POST of resource and returns the urn:uuid it is known by
+// within the Bundle. Other entries can reference it by that url; the server
+// rewrites such references to the id it assigns.
+func (tx *Transaction) Create(resource any, opts ...func(*WriteOptions)) string {
+	body, head, err := encodeResource(resource)
+	if err != nil {
+		tx.fail(err)
+		return ""
+	}
+
+	fullUrl := "urn:uuid:" + newUUID()
+	tx.add(http.MethodPost, head.ResourceType, body, writeOptions(opts)).FullUrl = fullUrl
+
+	return fullUrl
+}
+
+// Update adds a PUT of resource, with If-Match set from meta.versionId as
+// in Client.Update.
+func (tx *Transaction) Update(resource any, opts ...func(*WriteOptions)) *Transaction {
+	o := writeOptions(opts)
+
+	body, head, err := encodeResource(resource)
+	if err != nil {
+		tx.fail(err)
+		return tx
+	}
+
+	if o.IfMatch == "" && !o.IgnoreVersion {
+		o.IfMatch = head.Meta.VersionId
+	}
+
+	u, err := entryUrl(head.ResourceType, head.Id, o)
+	if err != nil {
+		tx.fail(err)
+		return tx
+	}
+
+	tx.add(http.MethodPut, u, body, o)
+	return tx
+}
+
+// Patch adds a PATCH of the resource. A FHIRPatch is sent as its Parameters
+// resource, a JSONPatch wrapped in a Binary as FHIR requires.
+func (tx *Transaction) Patch(resourceType, id string, patch Patch, opts ...func(*WriteOptions)) *Transaction {
+	o := writeOptions(opts)
+
+	u, err := entryUrl(resourceType, id, o)
+	if err != nil {
+		tx.fail(err)
+		return tx
+	}
+
+	body, err := json.Marshal(patch)
+	if err != nil {
+		tx.fail(err)
+		return tx
+	}
+
+	if _, ok := patch.(JSONPatch); ok {
+		body = mustJSON(map[string]string{
+			"resourceType": "Binary",
+			"contentType":  patch.ContentType(),
+			"data":         base64.StdEncoding.EncodeToString(body),
+		})
+	}
+
+	tx.add(http.MethodPatch, u, body, o)
+	return tx
+}
+
+func (tx *Transaction) Delete(resourceType, id string, opts ...func(*WriteOptions)) *Transaction {
+	o := writeOptions(opts)
+
+	u, err := entryUrl(resourceType, id, o)
+	if err != nil {
+		tx.fail(err)
+		return tx
+	}
+
+	tx.add(http.MethodDelete, u, nil, o)
+	return tx
+}
+
+// Read adds a GET of url, relative to the base, e.g. "Task/1" or
+// "Task?status=ready". Reads are only allowed in batches.
+func (tx *Transaction) Read(url string) *Transaction {
+	tx.add(http.MethodGet, url, nil, WriteOptions{})
+	return tx
+}
+
+func (tx *Transaction) MarshalJSON() ([]byte, error) {
+	if tx.err != nil {
+		return nil, tx.err
+	}
+
+	return json.Marshal(struct {
+		ResourceType string             `json:"resourceType"`
+		Type         string             `json:"type"`
+		Entry        []TransactionEntry `json:"entry"`
+	}{"Bundle", tx.bundleType, tx.entries})
+}
+
+// entryUrl is the Bundle-relative counterpart of Client.writeUrl.
+func entryUrl(resourceType, id string, o WriteOptions) (string, error) {
+	if len(o.Where) != 0 {
+		return resourceType + "?" + o.Where.Encode(), nil
+	}
+
+	if id == "" {
+		return "", fmt.Errorf("%s has no id", resourceType)
+	}
+
+	return p.Format("%s/%s", resourceType, id), nil
+}
+
+// TransactionResponse is the batch-response or transaction-response Bundle
+// the server answers a Submit with, one entry per request in order.
+type TransactionResponse struct {
+	ResourceType string             `json:"resourceType,omitzero"`
+	Type         string             `json:"type,omitzero"`
+	Entry        []TransactionEntry `json:"entry,omitzero"`
+}
+
+// Err joins an *EntryError for every entry that didn't succeed, which can
+// only happen in a batch. It returns nil if all of them did.
+func (r TransactionResponse) Err() error {
+	errs := make([]error, 0)
+	for i, entry := range r.Entry {
+		code := entry.Response.StatusCode()
+		if code >= 200 && code <= 299 {
+			continue
+		}
+
+		err := errors.New(entry.Response.Status)
+		var oo OperationOutcome
+		if json.Unmarshal(entry.Response.Outcome, &oo) == nil && len(oo.Issue) != 0 {
+			err = errors.New(strings.TrimSpace(entry.Response.Status + " " + oo.Issue[0].String()))
+		}
+
+		errs = append(errs, &EntryError{Reference: p.Format("entry %d", i), Err: err})
+	}
+
+	return errors.Join(errs...)
+}
+
+// Submit POSTs a batch or transaction Bundle, either a *Transaction or raw
+// JSON, to the base URL. A transaction that fails as a whole returns an
+// *Error; individual batch failures are reported by TransactionResponse.Err.
+func (client *Client) Submit(ctx context.Context, bundle any) (TransactionResponse, error) {
+	body, head, err := encodeResource(bundle)
+	if err != nil {
+		return TransactionResponse{}, err
+	}
+
+	if head.ResourceType != "Bundle" {
+		return TransactionResponse{}, fmt.Errorf("expected a Bundle, got %s", head.ResourceType)
+	}
+
+	var bundleType struct{ Type string }
+	if err = json.Unmarshal(body, &bundleType); err != nil || (bundleType.Type != "batch" && bundleType.Type != "transaction") {
+		return TransactionResponse{}, fmt.Errorf("expected a batch or transaction Bundle, got type %q", bundleType.Type)
+	}
+
+	var resp TransactionResponse
+	err = client.write(ctx, http.MethodPost, client.reqUrl(), "application/fhir+json", body, nil, &resp)
+	return resp, err
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return p.Format("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
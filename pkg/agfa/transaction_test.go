@@ -0,0 +1,101 @@
+package agfa
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransaction(t *testing.T) {
+	status, err := NewValue("Code", "in-progress")
+	require.NoError(t, err)
+
+	tx := NewTransaction()
+	ref := tx.Create(json.RawMessage(`{"resourceType": "Communication", "status": "completed"}`))
+	tx.Update(Task{ResourceType: "Task", Id: "1", Meta: ResourceMeta{VersionId: "5"}, Status: "ready"})
+	tx.Patch("Task", "2", NewFHIRPatch().Replace("Task.status", status))
+	tx.Patch("Task", "3", JSONPatch{{Op: "replace", Path: "/status", Value: "ready"}})
+	tx.Delete("Task", "4")
+	require.Equal(t, 5, tx.Len())
+	require.True(t, strings.HasPrefix(ref, "urn:uuid:"))
+	require.Len(t, ref, len("urn:uuid:")+36)
+
+	body, err := json.Marshal(tx)
+	require.NoError(t, err)
+
+	var bundle struct {
+		ResourceType string
+		Type         string
+		Entry        []TransactionEntry
+	}
+	require.NoError(t, json.Unmarshal(body, &bundle))
+	require.Equal(t, "Bundle", bundle.ResourceType)
+	require.Equal(t, "transaction", bundle.Type)
+	require.Equal(t, ref, bundle.Entry[0].FullUrl)
+	require.Equal(t, BundleEntryRequest{Method: "POST", Url: "Communication"}, bundle.Entry[0].Request)
+	require.Equal(t, BundleEntryRequest{Method: "PUT", Url: "Task/1", IfMatch: `W/"5"`}, bundle.Entry[1].Request)
+	require.Equal(t, "PATCH", bundle.Entry[2].Request.Method)
+	require.Contains(t, string(bundle.Entry[2].Resource), `"Parameters"`)
+
+	var binary struct{ ResourceType, ContentType, Data string }
+	require.NoError(t, json.Unmarshal(bundle.Entry[3].Resource, &binary))
+	require.Equal(t, "Binary", binary.ResourceType)
+	require.Equal(t, "application/json-patch+json", binary.ContentType)
+	data, err := base64.StdEncoding.DecodeString(binary.Data)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"op": "replace", "path": "/status", "value": "ready"}]`, string(data))
+
+	require.Equal(t, BundleEntryRequest{Method: "DELETE", Url: "Task/4"}, bundle.Entry[4].Request)
+	require.Nil(t, bundle.Entry[4].Resource)
+}
+
+func TestTransaction_BuilderError(t *testing.T) {
+	tx := NewBatch()
+	tx.Read("Task/1")
+	tx.Update(json.RawMessage(`{"resourceType": "Task"}`))
+
+	_, err := json.Marshal(tx)
+	require.ErrorContains(t, err, "transaction entry 1: Task has no id")
+}
+
+func TestClientSubmit(t *testing.T) {
+	ts, c := newWriteServer(t, http.StatusOK, `{
+		"resourceType": "Bundle",
+		"type": "batch-response",
+		"entry": [
+			{"response": {"status": "200 OK", "etag": "W/\"2\""}},
+			{"response": {"status": "412 Precondition Failed", "outcome": {
+				"resourceType": "OperationOutcome",
+				"issue": [{"severity": "error", "code": "conflict", "diagnostics": "version mismatch"}]
+			}}}
+		]
+	}`)
+	client := newClientWithServer(ts)
+
+	tx := NewBatch().Delete("Task", "1").Delete("Task", "2", IfMatch("1"))
+
+	resp, err := client.Submit(context.Background(), tx)
+	require.NoError(t, err)
+	require.Equal(t, http.MethodPost, c.method)
+	require.Equal(t, "/", c.url)
+	require.Contains(t, c.body, `"type":"batch"`)
+
+	require.Len(t, resp.Entry, 2)
+	require.Equal(t, 200, resp.Entry[0].Response.StatusCode())
+
+	entryErrs := EntryErrors(resp.Err())
+	require.Len(t, entryErrs, 1)
+	require.Equal(t, "entry 1", entryErrs[0].Reference)
+	require.ErrorContains(t, entryErrs[0], "412 Precondition Failed error [conflict]: version mismatch")
+
+	_, err = client.Submit(context.Background(), json.RawMessage(`{"resourceType": "Bundle", "type": "searchset"}`))
+	require.ErrorContains(t, err, "batch or transaction")
+
+	_, err = client.Submit(context.Background(), Task{ResourceType: "Task"})
+	require.ErrorContains(t, err, "expected a Bundle")
+}
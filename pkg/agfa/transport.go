@@ -0,0 +1,173 @@
+package agfa
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Middleware decorates a RoundTripper, e.g. to log, trace or sign
+// requests.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Chain is a stack of middleware. The first one sees a request first and
+// its response last.
+type Chain []Middleware
+
+// Then wraps rt, or http.DefaultTransport if rt is nil, in the chain.
+func (c Chain) Then(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	for i := len(c) - 1; i >= 0; i-- {
+		rt = c[i](rt)
+	}
+
+	return rt
+}
+
+// RoundTripperFunc adapts a function to http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithHTTPClient makes the client send requests through a copy of hc. A
+// cookie jar is added if hc has none, since session logins depend on one;
+// Client.State only sees the cookies of the jar the client created.
+func WithHTTPClient(hc *http.Client) func(*Client) {
+	return func(client *Client) {
+		c := *hc
+		if c.Jar == nil {
+			c.Jar = newRecordingJar()
+		}
+		client.hc = &c
+	}
+}
+
+// WithTransport sends requests through rt rather than http.DefaultTransport.
+// TLS and proxy options require rt to be an *http.Transport.
+func WithTransport(rt http.RoundTripper) func(*Client) {
+	return func(client *Client) {
+		client.transport = rt
+	}
+}
+
+// WithMiddleware wraps the client's transport in mw, after any middleware
+// added before.
+func WithMiddleware(mw ...Middleware) func(*Client) {
+	return func(client *Client) {
+		client.middleware = append(client.middleware, mw...)
+	}
+}
+
+// WithTimeout limits how long a single request may take, including reading
+// the response body.
+func WithTimeout(d time.Duration) func(*Client) {
+	return func(client *Client) {
+		client.timeout = d
+	}
+}
+
+// WithProxy sends requests through the HTTP(S) proxy at proxyUrl, in place
+// of the one set by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables. A
+// nil proxyUrl connects directly.
+func WithProxy(proxyUrl *url.URL) func(*Client) {
+	return func(client *Client) {
+		client.proxy = http.ProxyURL(proxyUrl)
+	}
+}
+
+// WithUserAgent sets the User-Agent header of requests which don't set
+// their own.
+func WithUserAgent(ua string) func(*Client) {
+	return func(client *Client) {
+		client.userAgent = ua
+	}
+}
+
+// buildTransport assembles the http client's transport from the options.
+// Options that failed make every request fail, since NewClient can't
+// report errors itself.
+func (client *Client) buildTransport() {
+	if client.timeout > 0 {
+		client.hc.Timeout = client.timeout
+	}
+
+	if client.optErr != nil {
+		client.hc.Transport = errTransport{client.optErr}
+		return
+	}
+
+	rt := client.transport
+	if rt == nil {
+		rt = client.hc.Transport
+	}
+
+	customTLS := client.tlsConfig != nil || !client.VerifySsl
+	if customTLS || client.proxy != nil {
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+
+		tr, ok := rt.(*http.Transport)
+		if !ok {
+			client.hc.Transport = errTransport{fmt.Errorf("TLS and proxy options need an *http.Transport, got %T", rt)}
+			return
+		}
+
+		tr = tr.Clone()
+		if customTLS {
+			conf := client.tlsConf()
+			conf.InsecureSkipVerify = !client.VerifySsl
+			tr.TLSClientConfig = conf
+		}
+		if client.proxy != nil {
+			tr.Proxy = client.proxy
+		}
+		rt = tr
+	}
+
+	chain := slices.Clone(client.middleware)
+	if client.userAgent != "" {
+		chain = append(Chain{userAgent(client.userAgent)}, chain...)
+	}
+	if len(client.hooks) != 0 {
+		// innermost, so hooks see requests as they go out on the wire
+		chain = append(chain, hookMiddleware(client.hooks, client.redactor))
+	}
+	if len(chain) != 0 {
+		rt = chain.Then(rt)
+	}
+
+	client.hc.Transport = rt
+}
+
+func userAgent(ua string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("User-Agent") == "" {
+				req = req.Clone(req.Context())
+				req.Header.Set("User-Agent", ua)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// errTransport fails every request with err.
+type errTransport struct {
+	err error
+}
+
+func (t errTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	return nil, t.err
+}
@@ -0,0 +1,82 @@
+package agfa
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "server")
+		require.Equal(t, "agfapi-test", r.Header.Get("User-Agent"))
+		io.WriteString(w, `{"foo": "bar"}`)
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, WithMiddleware(mw("a")), WithUserAgent("agfapi-test"), WithMiddleware(mw("b")))
+
+	var got objStruct
+	require.NoError(t, c.Get("Task/1", nil, &got))
+	require.Equal(t, []string{"a", "b", "server"}, order)
+}
+
+func TestWithProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// proxied requests carry the absolute url of the origin
+		require.Equal(t, "agfa.invalid", r.URL.Host)
+		io.WriteString(w, `{"foo": "proxied"}`)
+	}))
+	defer proxy.Close()
+
+	u, _ := url.Parse(proxy.URL)
+	c := NewClient("http://agfa.invalid/fhir", WithProxy(u))
+
+	var got objStruct
+	require.NoError(t, c.Get("Task/1", nil, &got))
+	require.Equal(t, "proxied", got.Foo)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	hc := &http.Client{Timeout: time.Minute}
+	c := NewClient("http://agfa.invalid", WithHTTPClient(hc), WithTimeout(time.Second))
+
+	require.NotNil(t, c.hc.Jar, "session logins need a cookie jar")
+	require.Nil(t, hc.Jar, "the caller's client is left alone")
+	require.Equal(t, time.Second, c.hc.Timeout)
+}
+
+func TestWithTransport(t *testing.T) {
+	calls := 0
+	rt := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"foo": "bar"}`)
+	}))
+	defer ts.Close()
+
+	var got objStruct
+	require.NoError(t, NewClient(ts.URL, WithTransport(rt)).Get("Task/1", nil, &got))
+	require.Equal(t, 1, calls)
+
+	err := NewClient(ts.URL, WithTransport(rt), WithInsecureSkipVerify()).Get("Task/1", nil, &got)
+	require.ErrorContains(t, err, "need an *http.Transport")
+}
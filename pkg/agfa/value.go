@@ -0,0 +1,308 @@
+package agfa
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"strings"
+)
+
+// Value is a FHIR choice-type element, i.e. value[x]. Type is the suffix
+// the element was sent with (e.g. "String" for valueString, "CodeableConcept"
+// for valueCodeableConcept) and Raw its undecoded JSON. The zero Value means
+// the element was absent.
+type Value struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+// ValueTypes lists every data type FHIR R4 allows for value[x].
+var ValueTypes = []string{
+	// primitives
+	"Base64Binary", "Boolean", "Canonical", "Code", "Date", "DateTime",
+	"Decimal", "Id", "Instant", "Integer", "Markdown", "Oid", "PositiveInt",
+	"String", "Time", "UnsignedInt", "Uri", "Url", "Uuid",
+	// general purpose
+	"Address", "Age", "Annotation", "Attachment", "CodeableConcept", "Coding",
+	"ContactPoint", "Count", "Distance", "Duration", "HumanName", "Identifier",
+	"Money", "Period", "Quantity", "Range", "Ratio", "Reference",
+	"SampledData", "Signature", "Timing",
+	// metadata
+	"ContactDetail", "Contributor", "DataRequirement", "Expression",
+	"ParameterDefinition", "RelatedArtifact", "TriggerDefinition",
+	"UsageContext",
+	// special purpose
+	"Dosage", "Meta",
+}
+
+var errValueType = errors.New("value is of another type")
+
+// NewValue encodes v as a value[x] of the given type, e.g.
+// NewValue("Reference", Reference{Reference: "Patient/1"}).
+func NewValue(typ string, v any) (Value, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return Value{}, err
+	}
+
+	return Value{Type: typ, Raw: raw}, nil
+}
+
+func (v Value) IsZero() bool {
+	return v.Type == ""
+}
+
+// Is reports whether the value has one of the given types.
+func (v Value) Is(types ...string) bool {
+	for _, typ := range types {
+		if v.Type == typ {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Decode unmarshals the value into dst, whatever its type.
+func (v Value) Decode(dst any) error {
+	if v.IsZero() {
+		return errors.New("value is absent")
+	}
+
+	return json.Unmarshal(v.Raw, dst)
+}
+
+func decodeAs[T any](v Value, types ...string) (T, error) {
+	var dst T
+	if !v.Is(types...) {
+		return dst, errValueType
+	}
+
+	err := v.Decode(&dst)
+	return dst, err
+}
+
+// AsString returns any of the string-like primitives, including codes, uris
+// and dates, which FHIR sends as JSON strings.
+func (v Value) AsString() (string, error) {
+	return decodeAs[string](v, "Base64Binary", "Canonical", "Code", "Date", "DateTime",
+		"Id", "Instant", "Markdown", "Oid", "String", "Time", "Uri", "Url", "Uuid")
+}
+
+func (v Value) AsBool() (bool, error) {
+	return decodeAs[bool](v, "Boolean")
+}
+
+func (v Value) AsInt() (int, error) {
+	return decodeAs[int](v, "Integer", "PositiveInt", "UnsignedInt")
+}
+
+func (v Value) AsDecimal() (float64, error) {
+	return decodeAs[float64](v, "Decimal")
+}
+
+func (v Value) AsCoding() (Coding, error) {
+	return decodeAs[Coding](v, "Coding")
+}
+
+func (v Value) AsCodeableConcept() (Code, error) {
+	return decodeAs[Code](v, "CodeableConcept")
+}
+
+func (v Value) AsReference() (Reference, error) {
+	return decodeAs[Reference](v, "Reference")
+}
+
+func (v Value) AsIdentifier() (ResourceIdentifier, error) {
+	return decodeAs[ResourceIdentifier](v, "Identifier")
+}
+
+// AsQuantity also covers the Quantity profiles Age, Count, Distance and
+// Duration.
+func (v Value) AsQuantity() (Quantity, error) {
+	return decodeAs[Quantity](v, "Quantity", "Age", "Count", "Distance", "Duration")
+}
+
+func (v Value) AsPeriod() (Period, error) {
+	return decodeAs[Period](v, "Period")
+}
+
+func (v Value) AsRange() (Range, error) {
+	return decodeAs[Range](v, "Range")
+}
+
+func (v Value) AsRatio() (Ratio, error) {
+	return decodeAs[Ratio](v, "Ratio")
+}
+
+func (v Value) AsMoney() (Money, error) {
+	return decodeAs[Money](v, "Money")
+}
+
+func (v Value) AsHumanName() (HumanName, error) {
+	return decodeAs[HumanName](v, "HumanName")
+}
+
+func (v Value) AsAddress() (Address, error) {
+	return decodeAs[Address](v, "Address")
+}
+
+func (v Value) AsContactPoint() (ContactPoint, error) {
+	return decodeAs[ContactPoint](v, "ContactPoint")
+}
+
+func (v Value) AsAttachment() (Attachment, error) {
+	return decodeAs[Attachment](v, "Attachment")
+}
+
+func (v Value) AsAnnotation() (Annotation, error) {
+	return decodeAs[Annotation](v, "Annotation")
+}
+
+// withMember returns a copy of extras with the value added as the JSON
+// member prefix[x].
+func (v Value) withMember(prefix string, extras Extras) Extras {
+	if v.IsZero() {
+		return extras
+	}
+
+	members := make(Extras, len(extras)+1)
+	maps.Copy(members, extras)
+	members[prefix+v.Type] = v.Raw
+
+	return members
+}
+
+// takeValue removes the prefix[x] member from extras and returns it.
+func takeValue(extras *Extras, prefix string) Value {
+	for k, raw := range *extras {
+		typ, ok := strings.CutPrefix(k, prefix)
+		if !ok {
+			continue
+		}
+
+		for _, valueType := range ValueTypes {
+			if strings.EqualFold(typ, valueType) {
+				delete(*extras, k)
+				if len(*extras) == 0 {
+					*extras = nil
+				}
+				return Value{Type: valueType, Raw: raw}
+			}
+		}
+	}
+
+	return Value{}
+}
+
+// Extension is a FHIR extension, carrying either a value or nested
+// extensions.
+type Extension struct {
+	Id        string     `json:"id,omitzero"`
+	Url       string     `json:"url,omitzero"`
+	Extension Extensions `json:"extension,omitzero"`
+	Value     Value      `json:"-"`
+
+	Extras Extras `json:"-"`
+}
+
+// UrlExtension is kept for compatibility; use Extension.
+type UrlExtension = Extension
+
+func (e *Extension) UnmarshalJSON(data []byte) error {
+	type extension Extension
+
+	if err := unmarshalWithExtras(data, (*extension)(e), &e.Extras); err != nil {
+		return err
+	}
+	e.Value = takeValue(&e.Extras, "value")
+
+	return nil
+}
+
+func (e Extension) MarshalJSON() ([]byte, error) {
+	type extension Extension
+	return marshalWithExtras(extension(e), e.Value.withMember("value", e.Extras))
+}
+
+// ValueInteger returns the extension's valueInteger, or 0.
+//
+// Deprecated: use Value.AsInt.
+func (e Extension) ValueInteger() int {
+	n, _ := decodeAs[int](e.Value, "Integer")
+	return n
+}
+
+// ValueBoolean returns the extension's valueBoolean, or false.
+//
+// Deprecated: use Value.AsBool.
+func (e Extension) ValueBoolean() bool {
+	b, _ := e.Value.AsBool()
+	return b
+}
+
+// ValueCode returns the extension's valueCode, or "".
+//
+// Deprecated: use Value.AsString.
+func (e Extension) ValueCode() string {
+	code, _ := decodeAs[string](e.Value, "Code")
+	return code
+}
+
+// Extensions is a list of extensions, as found on any FHIR element.
+type Extensions []Extension
+
+// Get returns the first extension with the given url.
+func (exts Extensions) Get(url string) (Extension, bool) {
+	for _, ext := range exts {
+		if ext.Url == url {
+			return ext, true
+		}
+	}
+
+	return Extension{}, false
+}
+
+// Lookup follows urls through nested extensions, e.g. Lookup(outer, inner)
+// returns the extension inner found inside the extension outer.
+func (exts Extensions) Lookup(urls ...string) (Extension, bool) {
+	var ext Extension
+	for i, url := range urls {
+		var ok bool
+		if ext, ok = exts.Get(url); !ok {
+			return Extension{}, false
+		}
+		if i < len(urls)-1 {
+			exts = ext.Extension
+		}
+	}
+
+	return ext, len(urls) != 0
+}
+
+// Value returns the value of the extension found by Lookup(urls...).
+func (exts Extensions) Value(urls ...string) (Value, bool) {
+	ext, ok := exts.Lookup(urls...)
+	return ext.Value, ok && !ext.Value.IsZero()
+}
+
+type Range struct {
+	Low  Quantity `json:"low,omitzero"`
+	High Quantity `json:"high,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type Ratio struct {
+	Numerator   Quantity `json:"numerator,omitzero"`
+	Denominator Quantity `json:"denominator,omitzero"`
+
+	Extras Extras `json:"-"`
+}
+
+type Money struct {
+	Value    json.Number `json:"value,omitzero"`
+	Currency string      `json:"currency,omitzero"`
+
+	Extras Extras `json:"-"`
+}
@@ -0,0 +1,109 @@
+package agfa
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtension_Values(t *testing.T) {
+	in := `{
+		"resourceType": "List",
+		"id": "wl",
+		"extension": [
+			{"url": "http://agfa.com/priority", "valueInteger": 0},
+			{"url": "http://agfa.com/shared", "valueBoolean": false},
+			{"url": "http://agfa.com/owner", "valueReference": {"reference": "Practitioner/1"}},
+			{"url": "http://agfa.com/updated", "valueDateTime": "2025-11-26T08:00:00Z"},
+			{"url": "http://agfa.com/filter", "extension": [
+				{"url": "modality", "valueCodeableConcept": {"coding": [{"code": "CT"}]}},
+				{"url": "site", "valueString": "MAIN"}
+			]}
+		]
+	}`
+
+	var list List
+	require.NoError(t, json.Unmarshal([]byte(in), &list))
+	require.Len(t, list.Extension, 5)
+	require.Nil(t, list.Extras)
+
+	v, ok := list.Extension.Value("http://agfa.com/priority")
+	require.True(t, ok)
+	n, err := v.AsInt()
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	v, _ = list.Extension.Value("http://agfa.com/shared")
+	b, err := v.AsBool()
+	require.NoError(t, err)
+	require.False(t, b)
+
+	v, _ = list.Extension.Value("http://agfa.com/owner")
+	ref, err := v.AsReference()
+	require.NoError(t, err)
+	require.Equal(t, "Practitioner/1", ref.Reference)
+
+	_, err = v.AsString()
+	require.Error(t, err)
+
+	v, _ = list.Extension.Value("http://agfa.com/updated")
+	s, err := v.AsString()
+	require.NoError(t, err)
+	require.Equal(t, "2025-11-26T08:00:00Z", s)
+	require.Equal(t, "DateTime", v.Type)
+
+	v, ok = list.Extension.Value("http://agfa.com/filter", "modality")
+	require.True(t, ok)
+	cc, err := v.AsCodeableConcept()
+	require.NoError(t, err)
+	require.Equal(t, "CT", cc.Coding[0].Code)
+
+	_, ok = list.Extension.Value("http://agfa.com/filter")
+	require.False(t, ok)
+	_, ok = list.Extension.Lookup("http://agfa.com/filter", "missing")
+	require.False(t, ok)
+	_, ok = list.Extension.Lookup()
+	require.False(t, ok)
+
+	out, err := json.Marshal(list)
+	require.NoError(t, err)
+	require.JSONEq(t, in, string(out))
+}
+
+func TestTaskInput_Value(t *testing.T) {
+	var task Task
+	err := json.Unmarshal([]byte(`{
+		"resourceType": "Task",
+		"input": [{"type": {"text": "order"}, "valueReference": {"reference": "ServiceRequest/sr1"}}]
+	}`), &task)
+	require.NoError(t, err)
+	require.Equal(t, "sr1", task.ServiceRequestId())
+	require.Equal(t, "order", task.Input[0].Type.Text)
+
+	value, err := NewValue("String", "x")
+	require.NoError(t, err)
+	task.Input[0].Value = value
+	require.Empty(t, task.ServiceRequestId())
+
+	out, err := json.Marshal(task.Input[0])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": {"text": "order"}, "valueString": "x"}`, string(out))
+}
+
+func TestExtension_DeprecatedAccessors(t *testing.T) {
+	var exts Extensions
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"url": "a", "valueInteger": 3},
+		{"url": "b", "valueBoolean": true},
+		{"url": "c", "valueCode": "CT"}
+	]`), &exts))
+
+	require.Equal(t, 3, exts[0].ValueInteger())
+	require.True(t, exts[1].ValueBoolean())
+	require.Equal(t, "CT", exts[2].ValueCode())
+
+	require.Zero(t, exts[2].ValueInteger())
+	require.False(t, exts[0].ValueBoolean())
+	require.Empty(t, exts[1].ValueCode())
+}
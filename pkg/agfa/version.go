@@ -0,0 +1,143 @@
+package agfa
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/s-hammon/p"
+)
+
+// Version identifies the version of a resource the server returned, taken
+// from the ETag and Last-Modified response headers.
+type Version struct {
+	ETag         string
+	VersionId    string
+	LastModified time.Time
+}
+
+func versionOf(resp *http.Response) Version {
+	v := Version{ETag: resp.Header.Get("ETag")}
+	v.VersionId = versionFromETag(v.ETag)
+	v.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	return v
+}
+
+// versionFromETag extracts the versionId from an ETag like W/"3".
+func versionFromETag(etag string) string {
+	etag = strings.TrimPrefix(etag, "W/")
+	return strings.Trim(etag, `"`)
+}
+
+// ReadOptions make Read conditional.
+type ReadOptions struct {
+	// IfNoneMatch is the versionId the caller already has.
+	IfNoneMatch     string
+	IfModifiedSince time.Time
+}
+
+func IfNoneMatch(versionId string) func(*ReadOptions) {
+	return func(o *ReadOptions) {
+		o.IfNoneMatch = versionId
+	}
+}
+
+func IfModifiedSince(t time.Time) func(*ReadOptions) {
+	return func(o *ReadOptions) {
+		o.IfModifiedSince = t
+	}
+}
+
+// ReadVersion is like Read, but also returns the version the server
+// reported. Given IfNoneMatch or IfModifiedSince, it returns ErrNotModified
+// instead of the resource when the caller's copy is still current:
+//
+//	task, v, err := agfa.ReadVersion[agfa.Task](ctx, client, id, agfa.IfNoneMatch(cached.Meta.VersionId))
+//	if errors.Is(err, agfa.ErrNotModified) {
+//		task = cached
+//	}
+func ReadVersion[T Resource](ctx context.Context, client *Client, id string, opts ...func(*ReadOptions)) (T, Version, error) {
+	var o ReadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	header := http.Header{}
+	if o.IfNoneMatch != "" {
+		header.Set("If-None-Match", `W/"`+o.IfNoneMatch+`"`)
+	}
+	if !o.IfModifiedSince.IsZero() {
+		header.Set("If-Modified-Since", o.IfModifiedSince.UTC().Format(http.TimeFormat))
+	}
+
+	var res T
+	resp, err := client.getWithHeader(ctx, client.reqUrl(typeName[T](), id), header)
+	if err != nil {
+		return res, Version{}, err
+	}
+	defer resp.Body.Close()
+
+	v := versionOf(resp)
+	if err = decode(resp.Body, &res); err != nil {
+		return res, v, fmt.Errorf("decode %s: %v", typeName[T](), err)
+	}
+
+	return res, v, nil
+}
+
+// VRead fetches the given version of a resource.
+func VRead[T Resource](ctx context.Context, client *Client, id, versionId string) (T, error) {
+	var res T
+	err := client.GetContext(ctx, p.Format("%s/%s/_history/%s", typeName[T](), id, versionId), nil, &res)
+	return res, err
+}
+
+// History yields the versions of a resource, newest first, across every
+// page of its _history. Entries recording a delete carry no resource and
+// are skipped.
+func History[T Resource](ctx context.Context, client *Client, id string, opts ...func(*SearchOptions)) iter.Seq2[T, error] {
+	var zero T
+
+	return func(yield func(T, error) bool) {
+		for entry, err := range client.History(ctx, typeName[T](), id, nil, opts...) {
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			if len(entry.Resource) == 0 {
+				continue
+			}
+
+			var res T
+			if err = entry.Decode(&res); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			if !yield(res, nil) {
+				return
+			}
+		}
+	}
+}
+
+// History runs [type]/[id]/_history, or [type]/_history if id is empty,
+// yielding every entry across pages. params may narrow it down, e.g. with
+// _since or _count.
+func (client *Client) History(ctx context.Context, resourceType, id string, params url.Values, opts ...func(*SearchOptions)) iter.Seq2[SearchEntry, error] {
+	var o SearchOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	u := client.reqUrl(resourceType, id, "_history")
+	u.RawQuery = params.Encode()
+
+	return client.pages(ctx, u, o)
+}
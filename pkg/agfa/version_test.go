@@ -0,0 +1,98 @@
+package agfa
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadVersion(t *testing.T) {
+	modified := time.Date(2025, 11, 26, 8, 0, 0, 0, time.UTC)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/Task/1", r.URL.Path)
+		if r.Header.Get("If-None-Match") == `W/"2"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !since.Before(modified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `W/"2"`)
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		io.WriteString(w, `{"resourceType": "Task", "id": "1", "meta": {"versionId": "2", "lastUpdated": "2025-11-26T08:00:00.123Z"}}`)
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+	ctx := context.Background()
+
+	task, v, err := ReadVersion[Task](ctx, client, "1")
+	require.NoError(t, err)
+	require.Equal(t, "2", task.Meta.VersionId)
+	require.Equal(t, 2025, task.Meta.Updated().Year())
+	require.Equal(t, `W/"2"`, v.ETag)
+	require.Equal(t, "2", v.VersionId)
+	require.True(t, modified.Equal(v.LastModified))
+
+	_, _, err = ReadVersion[Task](ctx, client, "1", IfNoneMatch("2"))
+	require.True(t, errors.Is(err, ErrNotModified))
+
+	_, _, err = ReadVersion[Task](ctx, client, "1", IfNoneMatch("1"))
+	require.NoError(t, err)
+
+	_, _, err = ReadVersion[Task](ctx, client, "1", IfModifiedSince(modified))
+	require.ErrorIs(t, err, ErrNotModified)
+}
+
+func TestVReadAndHistory(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/Task/1/_history/1":
+			io.WriteString(w, `{"resourceType": "Task", "id": "1", "status": "ready", "meta": {"versionId": "1"}}`)
+		case "/Task/1/_history":
+			io.WriteString(w, `{"resourceType": "Bundle", "type": "history", "entry": [
+				{"resource": {"resourceType": "Task", "id": "1", "status": "in-progress", "meta": {"versionId": "2"}}},
+				{"request": {"method": "DELETE"}},
+				{"resource": {"resourceType": "Task", "id": "1", "status": "ready", "meta": {"versionId": "1"}}}
+			]}`)
+		case "/Task/_history":
+			io.WriteString(w, `{"resourceType": "Bundle", "type": "history", "entry": []}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+	ctx := context.Background()
+
+	task, err := VRead[Task](ctx, client, "1", "1")
+	require.NoError(t, err)
+	require.Equal(t, "ready", task.Status)
+
+	versions := make([]string, 0)
+	for task, err := range History[Task](ctx, client, "1") {
+		require.NoError(t, err)
+		versions = append(versions, task.Meta.VersionId)
+	}
+	require.Equal(t, []string{"2", "1"}, versions)
+
+	for _, err := range client.History(ctx, "Task", "", nil) {
+		require.NoError(t, err)
+	}
+}
+
+func TestVersionFromETag(t *testing.T) {
+	require.Equal(t, "3", versionFromETag(`W/"3"`))
+	require.Equal(t, "3", versionFromETag(`"3"`))
+	require.Empty(t, versionFromETag(""))
+}
@@ -0,0 +1,234 @@
+package agfa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/s-hammon/p"
+)
+
+// WorklistItem is a Task from a worklist along with the ServiceRequest its
+// first input references.
+type WorklistItem struct {
+	Task           Task
+	ServiceRequest ServiceRequest
+}
+
+// EntryError records why a single worklist entry could not be resolved.
+type EntryError struct {
+	Reference string
+	Err       error
+}
+
+func (e *EntryError) Error() string {
+	return p.Format("%s: %v", e.Reference, e.Err)
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// Worklist is a List resolved into its Tasks and ServiceRequests.
+type Worklist struct {
+	List  List
+	Items []WorklistItem
+}
+
+// ServiceRequests returns the ServiceRequest of every resolved item.
+func (wl Worklist) ServiceRequests() []ServiceRequest {
+	svcReqs := make([]ServiceRequest, len(wl.Items))
+	for i, item := range wl.Items {
+		svcReqs[i] = item.ServiceRequest
+	}
+
+	return svcReqs
+}
+
+func (client *Client) ResolveWorklist(listId string) (Worklist, error) {
+	return client.ResolveWorklistContext(context.Background(), listId)
+}
+
+// ResolveWorklistContext fetches the List and resolves every Task entry on
+// it. Entries that fail are left out of Items; their *EntryErrors are
+// returned joined together, so a non-nil error may come with a partially
+// filled Worklist.
+func (client *Client) ResolveWorklistContext(ctx context.Context, listId string) (Worklist, error) {
+	list, err := client.FetchListByIdContext(ctx, listId)
+	if err != nil {
+		return Worklist{}, fmt.Errorf("FetchListById: %w", err)
+	}
+
+	refs := make([]string, 0, len(list.Entry))
+	taskIds := make([]string, 0, len(list.Entry))
+	for _, e := range list.Entry {
+		if e.Item.IsTask() {
+			refs = append(refs, e.Item.Reference)
+			taskIds = append(taskIds, e.Item.ExtractTaskId())
+		}
+	}
+
+	var (
+		results []WorklistItem
+		errs    []error
+	)
+	if client.batchSize > 1 {
+		results, errs = client.resolveTasksBatched(ctx, taskIds)
+	} else {
+		results, errs = FetchAll(ctx, client.concurrency, taskIds, client.resolveTask)
+	}
+	if err := ctx.Err(); err != nil {
+		return Worklist{}, err
+	}
+
+	wl := Worklist{List: list, Items: make([]WorklistItem, 0, len(results))}
+	entryErrs := make([]error, 0)
+	for i, item := range results {
+		if errs[i] != nil {
+			entryErrs = append(entryErrs, &EntryError{Reference: refs[i], Err: errs[i]})
+			continue
+		}
+		wl.Items = append(wl.Items, item)
+	}
+
+	return wl, errors.Join(entryErrs...)
+}
+
+func (client *Client) resolveTask(ctx context.Context, taskId string) (WorklistItem, error) {
+	task, err := client.FetchTaskByIdContext(ctx, taskId)
+	if err != nil {
+		return WorklistItem{}, fmt.Errorf("FetchTaskById: %w", err)
+	}
+
+	return client.resolveServiceRequest(ctx, task)
+}
+
+func (client *Client) resolveServiceRequest(ctx context.Context, task Task) (WorklistItem, error) {
+	reqId := task.ServiceRequestId()
+	if reqId == "" {
+		return WorklistItem{}, errors.New("task has no ServiceRequest input")
+	}
+
+	svcReq, err := client.FetchServiceRequestByIdContext(ctx, reqId)
+	if err != nil {
+		return WorklistItem{}, fmt.Errorf("FetchServiceRequestById %q: %w", reqId, err)
+	}
+
+	return WorklistItem{Task: task, ServiceRequest: svcReq}, nil
+}
+
+// taskBatch holds the resources returned by one batched Task search.
+type taskBatch struct {
+	tasks   []Task
+	svcReqs []ServiceRequest
+}
+
+// resolveTasksBatched searches Tasks batchSize ids at a time, including the
+// ServiceRequests they reference. Anything a batch didn't return, whether
+// because the server rejected the search, ignored _include or paged the
+// results, is fetched with a GET per resource instead. Other failures of a
+// search, e.g. throttling or an expired session, are reported for each of
+// its Tasks rather than retried one GET at a time.
+func (client *Client) resolveTasksBatched(ctx context.Context, taskIds []string) ([]WorklistItem, []error) {
+	chunks := slices.Collect(slices.Chunk(taskIds, client.batchSize))
+	batches, batchErrs := FetchAll(ctx, client.concurrency, chunks, client.searchTasks)
+
+	tasks := make(map[string]Task, len(taskIds))
+	svcReqs := make(map[string]ServiceRequest, len(taskIds))
+	failed := make(map[string]error)
+	for i, batch := range batches {
+		if err := batchErrs[i]; err != nil && !searchRejected(err) {
+			for _, taskId := range chunks[i] {
+				failed[taskId] = fmt.Errorf("search Tasks: %w", err)
+			}
+			continue
+		}
+		for _, task := range batch.tasks {
+			tasks[task.Id] = task
+		}
+		for _, svcReq := range batch.svcReqs {
+			svcReqs[svcReq.Id] = svcReq
+		}
+	}
+
+	return FetchAll(ctx, client.concurrency, taskIds, func(ctx context.Context, taskId string) (WorklistItem, error) {
+		if err, ok := failed[taskId]; ok {
+			return WorklistItem{}, err
+		}
+
+		task, ok := tasks[taskId]
+		if !ok {
+			return client.resolveTask(ctx, taskId)
+		}
+
+		if svcReq, ok := svcReqs[task.ServiceRequestId()]; ok {
+			return WorklistItem{Task: task, ServiceRequest: svcReq}, nil
+		}
+
+		return client.resolveServiceRequest(ctx, task)
+	})
+}
+
+// searchRejected reports whether err means the server doesn't support the
+// batched Task search, as opposed to failing for some other reason.
+func searchRejected(err error) bool {
+	return hasStatus(err, http.StatusBadRequest, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented)
+}
+
+func (client *Client) searchTasks(ctx context.Context, taskIds []string) (taskBatch, error) {
+	params := NewSearchParams().
+		Id(taskIds...).
+		Include("Task", "input").
+		Count(2*len(taskIds)).
+		Add("_format", "json")
+
+	var bundle rawBundle
+	if err := client.GetQuery(ctx, "Task", params.Values(), &bundle); err != nil {
+		return taskBatch{}, err
+	}
+
+	var batch taskBatch
+	for _, entry := range bundle.Entry {
+		switch entry.ResourceType() {
+		case "Task":
+			var task Task
+			if err := entry.Decode(&task); err != nil {
+				return taskBatch{}, err
+			}
+			batch.tasks = append(batch.tasks, task)
+		case "ServiceRequest":
+			var svcReq ServiceRequest
+			if err := entry.Decode(&svcReq); err != nil {
+				return taskBatch{}, err
+			}
+			batch.svcReqs = append(batch.svcReqs, svcReq)
+		}
+	}
+
+	return batch, nil
+}
+
+// EntryErrors unpacks the per-entry failures from an error returned by
+// ResolveWorklist.
+func EntryErrors(err error) []*EntryError {
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		var e *EntryError
+		if errors.As(err, &e) {
+			return []*EntryError{e}
+		}
+		return nil
+	}
+
+	entryErrs := make([]*EntryError, 0)
+	for _, err := range joined.Unwrap() {
+		var e *EntryError
+		if errors.As(err, &e) {
+			entryErrs = append(entryErrs, e)
+		}
+	}
+
+	return entryErrs
+}
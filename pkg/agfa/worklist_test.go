@@ -0,0 +1,171 @@
+package agfa
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newWorklistServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/fhir+json")
+		switch r.URL.Path {
+		case "/List/wl":
+			io.WriteString(w, `{
+				"resourceType": "List",
+				"id": "wl",
+				"entry": [
+					{"item": {"reference": "Task/1"}},
+					{"item": {"reference": "Task/2"}},
+					{"item": {"reference": "Patient/9"}},
+					{"item": {"reference": "Task/3"}}
+				]
+			}`)
+		case "/Task/1", "/Task/3":
+			id := r.URL.Path[len("/Task/"):]
+			io.WriteString(w, `{"resourceType": "Task", "id": "`+id+`", "input": [{"valueReference": {"reference": "ServiceRequest/sr`+id+`"}}]}`)
+		case "/ServiceRequest/sr1", "/ServiceRequest/sr3":
+			id := r.URL.Path[len("/ServiceRequest/"):]
+			io.WriteString(w, `{"resourceType": "ServiceRequest", "id": "`+id+`"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestResolveWorklist(t *testing.T) {
+	ts := newWorklistServer(t)
+	client := newClientWithServer(ts)
+
+	wl, err := client.ResolveWorklist("wl")
+	require.Error(t, err)
+	require.Equal(t, "wl", wl.List.Id)
+	require.Len(t, wl.Items, 2)
+	require.Equal(t, "1", wl.Items[0].Task.Id)
+	require.Equal(t, "sr1", wl.Items[0].ServiceRequest.Id)
+	require.Equal(t, "3", wl.Items[1].Task.Id)
+	require.Equal(t, "sr3", wl.Items[1].ServiceRequest.Id)
+
+	entryErrs := EntryErrors(err)
+	require.Len(t, entryErrs, 1)
+	require.Equal(t, "Task/2", entryErrs[0].Reference)
+	require.True(t, IsNotFound(entryErrs[0]))
+
+	svcReqs := wl.ServiceRequests()
+	require.Len(t, svcReqs, 2)
+}
+
+func TestResolveWorklist_ListError(t *testing.T) {
+	ts := newWorklistServer(t)
+	client := newClientWithServer(ts)
+
+	_, err := client.ResolveWorklist("missing")
+	require.True(t, IsNotFound(err))
+	require.Empty(t, EntryErrors(err))
+}
+
+func TestResolveWorklist_Cancelled(t *testing.T) {
+	ts := newWorklistServer(t)
+	client := newClientWithServer(ts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.ResolveWorklistContext(ctx, "wl")
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestResolveWorklist_Batched(t *testing.T) {
+	var searches, gets atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/fhir+json")
+		switch r.URL.Path {
+		case "/List/wl":
+			io.WriteString(w, `{"resourceType": "List", "id": "wl", "entry": [
+				{"item": {"reference": "Task/1"}},
+				{"item": {"reference": "Task/2"}},
+				{"item": {"reference": "Task/3"}}
+			]}`)
+		case "/Task":
+			searches.Add(1)
+			require.Equal(t, "Task:input", r.URL.Query().Get("_include"))
+			switch r.URL.Query().Get("_id") {
+			case "1,2":
+				// sr2 wasn't included, so it has to be fetched on its own
+				io.WriteString(w, `{"resourceType": "Bundle", "entry": [
+					{"resource": {"resourceType": "Task", "id": "1", "input": [{"valueReference": {"reference": "ServiceRequest/sr1"}}]}},
+					{"resource": {"resourceType": "Task", "id": "2", "input": [{"valueReference": {"reference": "ServiceRequest/sr2"}}]}},
+					{"resource": {"resourceType": "ServiceRequest", "id": "sr1"}}
+				]}`)
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		case "/Task/3":
+			gets.Add(1)
+			io.WriteString(w, `{"resourceType": "Task", "id": "3", "input": [{"valueReference": {"reference": "ServiceRequest/sr3"}}]}`)
+		case "/ServiceRequest/sr2", "/ServiceRequest/sr3":
+			gets.Add(1)
+			io.WriteString(w, `{"resourceType": "ServiceRequest", "id": "`+r.URL.Path[len("/ServiceRequest/"):]+`"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+	WithBatchSize(2)(client)
+
+	wl, err := client.ResolveWorklist("wl")
+	require.NoError(t, err)
+	require.Len(t, wl.Items, 3)
+	for i, id := range []string{"1", "2", "3"} {
+		require.Equal(t, id, wl.Items[i].Task.Id)
+		require.Equal(t, "sr"+id, wl.Items[i].ServiceRequest.Id)
+	}
+	require.EqualValues(t, 2, searches.Load())
+	require.EqualValues(t, 3, gets.Load())
+}
+
+func TestResolveWorklist_BatchThrottled(t *testing.T) {
+	var gets atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/fhir+json")
+		switch r.URL.Path {
+		case "/List/wl":
+			io.WriteString(w, `{"resourceType": "List", "id": "wl", "entry": [
+				{"item": {"reference": "Task/1"}},
+				{"item": {"reference": "Task/2"}}
+			]}`)
+		case "/Task":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			gets.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	client := newClientWithServer(ts)
+	WithBatchSize(2)(client)
+
+	wl, err := client.ResolveWorklist("wl")
+	require.Empty(t, wl.Items)
+	entryErrs := EntryErrors(err)
+	require.Len(t, entryErrs, 2)
+	for _, e := range entryErrs {
+		require.True(t, IsRateLimited(e))
+	}
+	require.Zero(t, gets.Load(), "a throttled search must not fall back to single GETs")
+}
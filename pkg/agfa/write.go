@@ -0,0 +1,250 @@
+package agfa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// WriteOptions tune how Create, Update, Patch and Delete address the server.
+type WriteOptions struct {
+	// IfMatch is the versionId the resource must still be at on the server.
+	// Update fills it in from meta.versionId unless IgnoreVersion is set.
+	IfMatch       string
+	IgnoreVersion bool
+	// IfNoneExist makes Create a no-op if a resource matches the query.
+	IfNoneExist url.Values
+	// Where addresses the resource by search criteria instead of by id,
+	// turning the request into a conditional update, patch or delete.
+	Where url.Values
+}
+
+func IfMatch(versionId string) func(*WriteOptions) {
+	return func(o *WriteOptions) {
+		o.IfMatch = versionId
+	}
+}
+
+// IgnoreVersion makes Update overwrite the resource whatever its current
+// version on the server.
+func IgnoreVersion() func(*WriteOptions) {
+	return func(o *WriteOptions) {
+		o.IgnoreVersion = true
+	}
+}
+
+func IfNoneExist(query url.Values) func(*WriteOptions) {
+	return func(o *WriteOptions) {
+		o.IfNoneExist = query
+	}
+}
+
+func Where(query url.Values) func(*WriteOptions) {
+	return func(o *WriteOptions) {
+		o.Where = query
+	}
+}
+
+// resourceHead holds what the write methods need to know about a resource
+// to address it.
+type resourceHead struct {
+	ResourceType string
+	Id           string
+	Meta         ResourceMeta
+}
+
+// encodeResource marshals resource, unless it is JSON already, and peeks at
+// its type, id and version.
+func encodeResource(resource any) ([]byte, resourceHead, error) {
+	var (
+		body []byte
+		head resourceHead
+		err  error
+	)
+
+	switch r := resource.(type) {
+	case []byte:
+		body = r
+	case json.RawMessage:
+		body = r
+	default:
+		if body, err = json.Marshal(resource); err != nil {
+			return nil, head, fmt.Errorf("encode resource: %v", err)
+		}
+	}
+
+	if err = json.Unmarshal(body, &head); err != nil {
+		return nil, head, fmt.Errorf("decode resource: %v", err)
+	}
+
+	if head.ResourceType == "" {
+		return nil, head, errors.New("resource has no resourceType")
+	}
+
+	return body, head, nil
+}
+
+// Create POSTs resource, a struct or raw JSON, to the endpoint of its type
+// and decodes the server's copy into out, unless out is nil.
+func (client *Client) Create(ctx context.Context, resource any, out any, opts ...func(*WriteOptions)) error {
+	o := writeOptions(opts)
+
+	body, head, err := encodeResource(resource)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if len(o.IfNoneExist) != 0 {
+		header.Set("If-None-Exist", o.IfNoneExist.Encode())
+	}
+
+	u := client.reqUrl(head.ResourceType)
+	return client.write(ctx, http.MethodPost, u, "application/fhir+json", body, header, out)
+}
+
+// Update PUTs resource to [type]/[id], both taken from the resource itself.
+// If it carries meta.versionId, that is sent as If-Match so that the update
+// fails with a 412 if someone else changed the resource in the meantime.
+func (client *Client) Update(ctx context.Context, resource any, out any, opts ...func(*WriteOptions)) error {
+	o := writeOptions(opts)
+
+	body, head, err := encodeResource(resource)
+	if err != nil {
+		return err
+	}
+
+	if o.IfMatch == "" && !o.IgnoreVersion {
+		o.IfMatch = head.Meta.VersionId
+	}
+
+	u, err := client.writeUrl(head.ResourceType, head.Id, o)
+	if err != nil {
+		return err
+	}
+
+	return client.write(ctx, http.MethodPut, u, "application/fhir+json", body, o.header(), out)
+}
+
+// Patch applies patch, either a JSONPatch or a FHIRPatch, to the resource
+// and decodes the patched copy into out, unless out is nil.
+func (client *Client) Patch(ctx context.Context, resourceType, id string, patch Patch, out any, opts ...func(*WriteOptions)) error {
+	o := writeOptions(opts)
+
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("encode patch: %v", err)
+	}
+
+	u, err := client.writeUrl(resourceType, id, o)
+	if err != nil {
+		return err
+	}
+
+	return client.write(ctx, http.MethodPatch, u, patch.ContentType(), body, o.header(), out)
+}
+
+func (client *Client) Delete(ctx context.Context, resourceType, id string, opts ...func(*WriteOptions)) error {
+	o := writeOptions(opts)
+
+	u, err := client.writeUrl(resourceType, id, o)
+	if err != nil {
+		return err
+	}
+
+	return client.write(ctx, http.MethodDelete, u, "", nil, o.header(), nil)
+}
+
+// Create is the typed counterpart of Client.Create.
+func Create[T Resource](ctx context.Context, client *Client, res T, opts ...func(*WriteOptions)) (T, error) {
+	var out T
+	err := client.Create(ctx, res, &out, opts...)
+	return out, err
+}
+
+// Update is the typed counterpart of Client.Update.
+func Update[T Resource](ctx context.Context, client *Client, res T, opts ...func(*WriteOptions)) (T, error) {
+	var out T
+	err := client.Update(ctx, res, &out, opts...)
+	return out, err
+}
+
+func writeOptions(opts []func(*WriteOptions)) WriteOptions {
+	var o WriteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func (o WriteOptions) header() http.Header {
+	header := http.Header{}
+	if o.IfMatch != "" {
+		header.Set("If-Match", `W/"`+o.IfMatch+`"`)
+	}
+
+	return header
+}
+
+// writeUrl addresses [type]/[id], or [type]?[criteria] for conditional
+// requests.
+func (client *Client) writeUrl(resourceType, id string, o WriteOptions) (*url.URL, error) {
+	if len(o.Where) != 0 {
+		u := client.reqUrl(resourceType)
+		u.RawQuery = o.Where.Encode()
+		return u, nil
+	}
+
+	if id == "" {
+		return nil, fmt.Errorf("%s has no id", resourceType)
+	}
+
+	return client.reqUrl(resourceType, id), nil
+}
+
+func (client *Client) write(ctx context.Context, method string, u *url.URL, contentType string, body []byte, header http.Header, out any) error {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	req, err := client.newRequest(ctx, method, u, r)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Prefer", "return=representation")
+	}
+
+	resp, err := client.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body)
+		return client.newError(resp, body)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err = decode(resp.Body, out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("decode response: %v", err)
+	}
+
+	return nil
+}
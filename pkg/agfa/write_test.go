@@ -0,0 +1,164 @@
+package agfa
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type capture struct {
+	method string
+	url    string
+	header http.Header
+	body   string
+}
+
+func newWriteServer(t *testing.T, status int, respBody string) (*httptest.Server, *capture) {
+	t.Helper()
+
+	c := &capture{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*c = capture{method: r.Method, url: r.URL.String(), header: r.Header, body: string(body)}
+
+		w.Header().Set("Content-Type", "application/fhir+json")
+		w.WriteHeader(status)
+		io.WriteString(w, respBody)
+	}))
+	t.Cleanup(ts.Close)
+
+	return ts, c
+}
+
+func TestClientCreate(t *testing.T) {
+	ts, c := newWriteServer(t, http.StatusCreated, `{"resourceType": "Task", "id": "new", "status": "requested"}`)
+	client := newClientWithServer(ts)
+
+	task, err := Create(context.Background(), client, Task{ResourceType: "Task", Status: "requested"},
+		IfNoneExist(url.Values{"identifier": {"abc"}}))
+	require.NoError(t, err)
+	require.Equal(t, "new", task.Id)
+
+	require.Equal(t, http.MethodPost, c.method)
+	require.Equal(t, "/Task", c.url)
+	require.Equal(t, "application/fhir+json", c.header.Get("Content-Type"))
+	require.Equal(t, "return=representation", c.header.Get("Prefer"))
+	require.Equal(t, "identifier=abc", c.header.Get("If-None-Exist"))
+	require.JSONEq(t, `{"resourceType": "Task", "status": "requested"}`, c.body)
+}
+
+func TestClientCreate_NoResourceType(t *testing.T) {
+	client := &Client{BaseUrl: "http://localhost"}
+	err := client.Create(context.Background(), json.RawMessage(`{"id": "1"}`), nil)
+	require.ErrorContains(t, err, "no resourceType")
+}
+
+func TestClientUpdate(t *testing.T) {
+	ts, c := newWriteServer(t, http.StatusOK, `{"resourceType": "Task", "id": "1", "meta": {"versionId": "4"}}`)
+	client := newClientWithServer(ts)
+
+	task := Task{ResourceType: "Task", Id: "1", Meta: ResourceMeta{VersionId: "3"}, Status: "in-progress"}
+	got, err := Update(context.Background(), client, task)
+	require.NoError(t, err)
+	require.Equal(t, "4", got.Meta.VersionId)
+	require.Equal(t, http.MethodPut, c.method)
+	require.Equal(t, "/Task/1", c.url)
+	require.Equal(t, `W/"3"`, c.header.Get("If-Match"))
+
+	_, err = Update(context.Background(), client, task, IgnoreVersion())
+	require.NoError(t, err)
+	require.Empty(t, c.header.Get("If-Match"))
+
+	err = client.Update(context.Background(), json.RawMessage(`{"resourceType": "Task", "status": "ready"}`), nil,
+		Where(url.Values{"identifier": {"acc|123"}}))
+	require.NoError(t, err)
+	require.Equal(t, "/Task?identifier=acc%7C123", c.url)
+
+	err = client.Update(context.Background(), json.RawMessage(`{"resourceType": "Task"}`), nil)
+	require.ErrorContains(t, err, "has no id")
+}
+
+func TestClientUpdate_Conflict(t *testing.T) {
+	ts, _ := newWriteServer(t, http.StatusPreconditionFailed, `{"resourceType": "OperationOutcome", "issue": [{"severity": "error", "code": "conflict"}]}`)
+	client := newClientWithServer(ts)
+
+	task := Task{ResourceType: "Task", Id: "1", Meta: ResourceMeta{VersionId: "3"}}
+	_, err := Update(context.Background(), client, task)
+
+	var fe *Error
+	require.ErrorAs(t, err, &fe)
+	require.Equal(t, http.StatusPreconditionFailed, fe.StatusCode)
+	require.Equal(t, http.MethodPut, fe.Method)
+}
+
+func TestClientPatch(t *testing.T) {
+	ts, c := newWriteServer(t, http.StatusOK, `{"resourceType": "Task", "id": "1", "status": "in-progress"}`)
+	client := newClientWithServer(ts)
+
+	patch := JSONPatch{{Op: "replace", Path: "/status", Value: "in-progress"}}
+
+	var task Task
+	err := client.Patch(context.Background(), "Task", "1", patch, &task, IfMatch("2"))
+	require.NoError(t, err)
+	require.Equal(t, "in-progress", task.Status)
+	require.Equal(t, http.MethodPatch, c.method)
+	require.Equal(t, "application/json-patch+json", c.header.Get("Content-Type"))
+	require.Equal(t, `W/"2"`, c.header.Get("If-Match"))
+	require.JSONEq(t, `[{"op": "replace", "path": "/status", "value": "in-progress"}]`, c.body)
+
+	status, err := NewValue("Code", "in-progress")
+	require.NoError(t, err)
+	owner, err := NewValue("Reference", Reference{Reference: "Practitioner/1"})
+	require.NoError(t, err)
+
+	fhirPatch := NewFHIRPatch().
+		Replace("Task.status", status).
+		Add("Task", "owner", owner).
+		Delete("Task.note")
+
+	err = client.Patch(context.Background(), "Task", "1", fhirPatch, nil)
+	require.NoError(t, err)
+	require.Equal(t, "application/fhir+json", c.header.Get("Content-Type"))
+	require.JSONEq(t, `{
+		"resourceType": "Parameters",
+		"parameter": [
+			{"name": "operation", "part": [
+				{"name": "type", "valueCode": "replace"},
+				{"name": "path", "valueString": "Task.status"},
+				{"name": "value", "valueCode": "in-progress"}
+			]},
+			{"name": "operation", "part": [
+				{"name": "type", "valueCode": "add"},
+				{"name": "path", "valueString": "Task"},
+				{"name": "name", "valueString": "owner"},
+				{"name": "value", "valueReference": {"reference": "Practitioner/1"}}
+			]},
+			{"name": "operation", "part": [
+				{"name": "type", "valueCode": "delete"},
+				{"name": "path", "valueString": "Task.note"}
+			]}
+		]
+	}`, c.body)
+
+	var decoded FHIRPatch
+	require.NoError(t, json.Unmarshal([]byte(c.body), &decoded))
+	require.Len(t, decoded.Parameter, 3)
+	require.Equal(t, "Code", decoded.Parameter[0].Part[0].Value.Type)
+}
+
+func TestClientDelete(t *testing.T) {
+	ts, c := newWriteServer(t, http.StatusNoContent, "")
+	client := newClientWithServer(ts)
+
+	err := client.Delete(context.Background(), "Communication", "c1")
+	require.NoError(t, err)
+	require.Equal(t, http.MethodDelete, c.method)
+	require.Equal(t, "/Communication/c1", c.url)
+	require.Empty(t, c.body)
+}